@@ -0,0 +1,49 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/waffleboot/giiter/internal/git"
+	"github.com/waffleboot/giiter/internal/output"
+)
+
+type absorbCommand struct {
+	config *git.Config
+}
+
+func makeAbsorbCommand(config *git.Config) *cobra.Command {
+	c := absorbCommand{
+		config: config,
+	}
+
+	return &cobra.Command{
+		Use:   "absorb",
+		Short: "absorb staged changes into feature commits which last touched those lines",
+		// PersistentPreRunE не нужен, см. main
+		RunE: c.run,
+	}
+}
+
+func (c *absorbCommand) run(cmd *cobra.Command, args []string) error {
+	baseBranch, featureBranch, err := c.config.Branches()
+	if err != nil {
+		return err
+	}
+
+	report, err := git.Absorb(cmd.Context(), baseBranch, featureBranch)
+	if report != nil {
+		for _, absorbed := range report.Absorbed {
+			output.Printf("absorbed %d hunks into %s\n", absorbed.Hunks, absorbed.Commit)
+		}
+
+		if report.Left > 0 {
+			output.Printf("%d hunks left staged\n", report.Left)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return refreshFeatureCommits(cmd, c.config)
+}
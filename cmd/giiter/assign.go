@@ -1,8 +1,7 @@
 package main
 
 import (
-	"fmt"
-	"strconv"
+	"context"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -20,7 +19,7 @@ func makeAssignCommand(config *git.Config) *cobra.Command {
 	}
 
 	return &cobra.Command{
-		Use:     "assign",
+		Use:     "assign <commit> <branch>",
 		Short:   "reassign commit to review branch",
 		Aliases: []string{"a"},
 		// PersistentPreRunE не нужен, см. main
@@ -35,56 +34,32 @@ func (c *assignCommand) run(cmd *cobra.Command, args []string) error {
 	}
 
 	if len(args) < 2 {
-		return errors.New("need new commit and old review branch position numbers")
+		return errors.New("need new commit and old review branch selectors")
 	}
 
-	commitPos, branchPos := args[0], args[1]
-
-	commitIndex, err := strconv.Atoi(commitPos)
+	records, err := git.State(cmd.Context(), baseBranch, featureBranch)
 	if err != nil {
 		return err
 	}
 
-	branchIndex, err := strconv.Atoi(branchPos)
-	if err != nil {
+	if err := assignRecord(cmd.Context(), records, args[0], args[1]); err != nil {
 		return err
 	}
 
-	records, err := git.State(cmd.Context(), baseBranch, featureBranch)
+	return listFeatureCommits(cmd.Context(), c.config, false)
+}
+
+// assignRecord переключает review ветку записи branchPos на коммит записи commitPos
+func assignRecord(ctx context.Context, records []git.Record, commitPos, branchPos string) error {
+	commitIndex, branchIndex, err := git.ResolveAssignment(records, commitPos, branchPos)
 	if err != nil {
 		return err
 	}
 
-	commitIndex--
-	branchIndex--
-
-	switch {
-	case commitIndex < 0:
-		return errors.New("commit position is negative")
-	case commitIndex > len(records):
-		return errors.New("commit position is greater then count of records")
-	case branchIndex < 0:
-		return errors.New("branch position is negative")
-	case branchIndex > len(records):
-		return errors.New("branch position is greater then count of records")
-	case commitIndex == branchIndex:
-		return errors.New("you point the same record")
-	case records[commitIndex].HasReview():
-		return fmt.Errorf("could not reassign commit %s with review", commitPos)
-	case !records[branchIndex].HasReview():
-		return fmt.Errorf("could not reassign commit %s without review", branchPos)
-	}
-
-	commit := records[commitIndex].CommitSHA()
-
 	branchName, err := records[branchIndex].AnyReviewBranch()
 	if err != nil {
 		return err
 	}
 
-	if err := git.SwitchBranch(cmd.Context(), branchName, commit); err != nil {
-		return err
-	}
-
-	return listFeatureCommits(cmd.Context(), c.config)
+	return git.SwitchBranch(ctx, branchName, records[commitIndex].CommitSHA())
 }
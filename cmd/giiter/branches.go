@@ -1,11 +1,10 @@
 package main
 
 import (
-	"fmt"
-
 	"github.com/spf13/cobra"
 
 	"github.com/waffleboot/giiter/internal/git"
+	"github.com/waffleboot/giiter/internal/output"
 )
 
 type branchesCommand struct {
@@ -20,9 +19,10 @@ func makeBranchesCommand(config *git.Config) *cobra.Command {
 	}
 
 	cmd := &cobra.Command{
-		Use:     "branches",
-		Short:   "show all review branches",
-		Aliases: []string{"b"},
+		Use:         "branches",
+		Annotations: map[string]string{annotationReadOnly: "true"},
+		Short:       "show all review branches",
+		Aliases:     []string{"b"},
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) (err error) {
 			if err := parentPersistentPreRunE(cmd, args); err != nil {
 				return err
@@ -49,7 +49,7 @@ func (c *branchesCommand) run(cmd *cobra.Command, args []string) error {
 	}
 
 	for _, branch := range reviewBranches {
-		fmt.Printf("%s\n", branch.BranchName())
+		output.Printf("%s\n", branch.BranchName())
 	}
 
 	return nil
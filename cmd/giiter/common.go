@@ -22,3 +22,17 @@ func addCommonFlags(cmd *cobra.Command, config *git.Config) {
 			Validate(cmd.Context())
 	}
 }
+
+// recordIndex возвращает индекс записи по селектору, см. git.Select
+func recordIndex(records []git.Record, selector string) (int, error) {
+	return git.SelectOne(records, selector)
+}
+
+func recordByPosition(records []git.Record, selector string) (*git.Record, error) {
+	index, err := recordIndex(records, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	return &records[index], nil
+}
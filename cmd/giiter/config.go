@@ -0,0 +1,119 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/waffleboot/giiter/internal/app"
+	"github.com/waffleboot/giiter/internal/output"
+)
+
+func makeConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "show and change settings",
+	}
+
+	cmd.AddCommand(makeConfigGetCommand())
+	cmd.AddCommand(makeConfigSetCommand())
+	cmd.AddCommand(makeConfigListCommand())
+
+	return cmd
+}
+
+func makeConfigGetCommand() *cobra.Command {
+	var showSecret bool
+
+	cmd := &cobra.Command{
+		Use:         "get <key>",
+		Annotations: map[string]string{annotationReadOnly: "true"},
+		Short:       "show effective value of setting",
+		Args:        cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			setting, err := app.FindSetting(args[0])
+			if err != nil {
+				return err
+			}
+
+			if showSecret {
+				output.Println(setting.Value())
+			} else {
+				output.Println(setting.Display())
+			}
+
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&showSecret, "show-secret", false, "show value of secret setting")
+
+	return cmd
+}
+
+func makeConfigSetCommand() *cobra.Command {
+	var user bool
+
+	cmd := &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "write setting to repository config file",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := output.CheckSetting(args[0], args[1]); err != nil {
+				return err
+			}
+
+			return app.SetSetting(args[0], args[1], user)
+		},
+	}
+	cmd.Flags().BoolVar(&user, "user", false, "write setting to user config file")
+
+	return cmd
+}
+
+func makeConfigListCommand() *cobra.Command {
+	var showOrigin bool
+
+	cmd := &cobra.Command{
+		Use:         "list",
+		Annotations: map[string]string{annotationReadOnly: "true"},
+		Short:       "show effective values of all settings",
+		Args:        cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, setting := range app.Settings() {
+				if showOrigin {
+					origin, err := settingOrigin(setting)
+					if err != nil {
+						return err
+					}
+
+					output.Printf("%s\t", origin)
+				}
+
+				output.Printf("%s=%s\n", setting.Key, setting.Display())
+			}
+
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&showOrigin, "show-origin", false, "show where each value comes from")
+
+	return cmd
+}
+
+func settingOrigin(setting app.Setting) (string, error) {
+	switch origin := app.Origin(setting.Key); origin {
+	case app.OriginUser:
+		userFile, err := app.UserConfigFile()
+		if err != nil {
+			return "", err
+		}
+
+		return origin + ":" + userFile, nil
+	case app.OriginRepo:
+		return origin + ":" + _cfgFile, nil
+	case app.OriginEnv:
+		return origin + ":" + setting.Env(), nil
+	case app.OriginFlag:
+		return origin + ":--" + setting.Flag, nil
+	default:
+		return origin, nil
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"github.com/waffleboot/giiter/internal/app"
+	"github.com/waffleboot/giiter/internal/git"
+	"github.com/waffleboot/giiter/internal/output"
+)
+
+func makeContinueCommand(config *git.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "continue",
+		Short: "continue giiter operation and refresh review branches",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return continueOperation(cmd, config)
+		},
+	}
+}
+
+// continueOperation продолжает операцию giiter и обновляет review ветки ее feature ветки
+func continueOperation(cmd *cobra.Command, config *git.Config) error {
+	op, err := git.Continue(cmd.Context())
+	if errors.Is(err, git.ErrEditStopped) {
+		output.Println(err.Error())
+
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	config.Add(
+		git.BaseBranch(op.BaseBranch),
+		git.FeatureBranch(op.FeatureBranch))
+
+	if err := refreshFeatureCommits(cmd, config); err != nil {
+		return err
+	}
+
+	// rebase --fetch остановился на конфликте уже после того, как нашел влитые записи
+	if op.Landed && app.Config.MergeRequestAutoReady {
+		markBottomReady(cmd.Context(), op.BaseBranch, op.FeatureBranch)
+	}
+
+	return nil
+}
+
+func makeAbortCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "abort",
+		Short: "abort giiter operation and restore feature branch",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return git.Abort(cmd.Context())
+		},
+	}
+}
@@ -49,6 +49,8 @@ func (c *deleteCommand) run(cmd *cobra.Command, args []string) error {
 		if err := git.DeleteBranch(cmd.Context(), branch.BranchName()); err != nil {
 			return err
 		}
+
+		git.ForgetReviewBranch(featureBranch, branch.BranchName())
 	}
 
 	return nil
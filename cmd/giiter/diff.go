@@ -2,16 +2,17 @@ package main
 
 import (
 	"fmt"
-	"strconv"
 
-	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
 	"github.com/waffleboot/giiter/internal/git"
+	"github.com/waffleboot/giiter/internal/output"
 )
 
 type diffCommand struct {
-	config *git.Config
+	config    *git.Config
+	interdiff bool
+	noPager   bool
 }
 
 func makeDiffCommand(config *git.Config) *cobra.Command {
@@ -19,14 +20,23 @@ func makeDiffCommand(config *git.Config) *cobra.Command {
 		config: config,
 	}
 
-	return &cobra.Command{
-		Use:     "diff",
-		Short:   "diff commit",
-		Aliases: []string{"d"},
-		Args:    cobra.MinimumNArgs(1),
+	cmd := &cobra.Command{
+		Use:   "diff <record> [<git diff args>...]",
+		Short: "diff commit",
+		Long: `Show changes of a record or of a range of records like 2..4.
+With --interdiff show what changed between the review branch and the feature commit,
+this is what reviewers will see on the next push.`,
+		Aliases:     []string{"d"},
+		Args:        cobra.MinimumNArgs(1),
+		Annotations: map[string]string{annotationReadOnly: "true"},
 		// PersistentPreRunE не нужен, см. main
 		RunE: c.run,
 	}
+
+	cmd.Flags().BoolVar(&c.interdiff, "interdiff", false, "show changes since the last push of the review branch")
+	cmd.Flags().BoolVar(&c.noPager, "no-pager", false, "do not pipe output into pager")
+
+	return cmd
 }
 
 func (c *diffCommand) run(cmd *cobra.Command, args []string) error {
@@ -35,32 +45,66 @@ func (c *diffCommand) run(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	shaPos := args[0]
+	records, err := git.State(cmd.Context(), baseBranch, featureBranch)
+	if err != nil {
+		return err
+	}
 
-	shaIndex, err := strconv.Atoi(shaPos)
+	indexes, err := git.SelectRange(records, args[0])
 	if err != nil {
 		return err
 	}
 
-	records, err := git.State(cmd.Context(), baseBranch, featureBranch)
+	if c.interdiff && len(indexes) > 1 {
+		return fmt.Errorf("--interdiff needs a single record, %s matches %d records", args[0], len(indexes))
+	}
+
+	if len(indexes) > 1 {
+		for _, i := range indexes {
+			if records[i].IsOldCommit() {
+				return fmt.Errorf("record %d is not in the feature branch, it could not be a part of a range", i+1)
+			}
+		}
+	}
+
+	stopPager, err := c.startPager(cmd)
 	if err != nil {
 		return err
 	}
 
-	switch {
-	case shaIndex < 0:
-		return errors.New("commit position is negative")
-	case shaIndex > len(records):
-		return errors.New("commit position is greater then count of records")
+	defer stopPager()
+
+	first, last := &records[indexes[0]], &records[indexes[len(indexes)-1]]
+
+	diffArgs := append([]string{output.GitColor()}, args[1:]...)
+
+	// range-diff сам выводит subject коммитов
+	if c.interdiff {
+		if err := git.Interdiff(cmd.Context(), output.Writer(), first, diffArgs...); err != nil {
+			return fmt.Errorf("record %d: %w", indexes[0]+1, err)
+		}
+
+		return nil
+	}
+
+	for _, i := range indexes {
+		record := &records[i]
+		output.Println(output.Paint("subject", "commit "+record.CommitSHA()+" message "+record.CommitMessage().Subject))
 	}
 
-	record := records[shaIndex-1]
+	return git.Diff(cmd.Context(), output.Writer(), first.DiffBase(), last.CommitSHA(), diffArgs...)
+}
 
-	fmt.Println(Yellow + "commit " + record.CommitSHA() + " message " + record.CommitMessage().Subject + Reset)
+// startPager запускает pager из настроек git и возвращает функцию, которая ждет его закрытия
+func (c *diffCommand) startPager(cmd *cobra.Command) (func(), error) {
+	if c.noPager {
+		return func() {}, nil
+	}
 
-	if err := git.Diff(cmd.Context(), record.CommitSHA(), args[1:]...); err != nil {
-		return err
+	pager, err := git.Pager(cmd.Context())
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return output.StartPager(pager)
 }
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/waffleboot/giiter/internal/git"
+	"github.com/waffleboot/giiter/internal/output"
+)
+
+type editCommand struct {
+	config *git.Config
+}
+
+func makeEditCommand(config *git.Config) *cobra.Command {
+	c := editCommand{
+		config: config,
+	}
+
+	return &cobra.Command{
+		Use:   "edit <record>",
+		Short: "edit commit and restack feature branch, staged changes are applied as fixup",
+		Args:  cobra.ExactArgs(1),
+		// PersistentPreRunE не нужен, см. main
+		RunE: c.run,
+	}
+}
+
+func (c *editCommand) run(cmd *cobra.Command, args []string) error {
+	baseBranch, featureBranch, err := c.config.Branches()
+	if err != nil {
+		return err
+	}
+
+	records, err := git.State(cmd.Context(), baseBranch, featureBranch)
+	if err != nil {
+		return err
+	}
+
+	record, err := recordByPosition(records, args[0])
+	if err != nil {
+		return err
+	}
+
+	stopped, err := git.Edit(cmd.Context(), baseBranch, featureBranch, record)
+	if err != nil {
+		return err
+	}
+
+	if stopped && len(record.Commits()) > 1 {
+		output.Printf("stopped at %s, the first commit of review group %s\n", record.Commits()[0], record.Group())
+		output.Println("amend it and run giiter continue for each commit of the group, or giiter abort")
+
+		return nil
+	}
+
+	if stopped {
+		output.Printf("stopped at %s %s\n", record.CommitSHA(), record.CommitMessage().Subject)
+		output.Println("amend the commit and run giiter continue, or giiter abort")
+
+		return nil
+	}
+
+	return refreshFeatureCommits(cmd, c.config)
+}
+
+func refreshFeatureCommits(cmd *cobra.Command, config *git.Config) error {
+	baseBranch, featureBranch, err := config.Branches()
+	if err != nil {
+		return err
+	}
+
+	if _, err := git.Refresh(cmd.Context(), baseBranch, featureBranch); err != nil {
+		return err
+	}
+
+	return listFeatureCommits(cmd.Context(), config, false)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"github.com/waffleboot/giiter/internal/app"
+	"github.com/waffleboot/giiter/internal/git"
+	"github.com/waffleboot/giiter/internal/output"
+)
+
+func makeFeatureCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "feature",
+		Short: "manage feature branches",
+	}
+
+	cmd.AddCommand(makeFeatureAddCommand())
+	cmd.AddCommand(makeFeatureListCommand())
+	cmd.AddCommand(makeFeatureRemoveCommand())
+	cmd.AddCommand(makeFeatureRenameCommand())
+
+	return cmd
+}
+
+func makeFeatureAddCommand() *cobra.Command {
+	var baseBranch string
+
+	cmd := &cobra.Command{
+		Use:   "add [<feature>]",
+		Short: "register feature branch, current branch by default",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if baseBranch == "" {
+				return errors.New("base branch is required")
+			}
+
+			var userFeatureBranch string
+			if len(args) > 0 {
+				userFeatureBranch = args[0]
+			}
+
+			featureBranch, err := git.FindFeatureBranch(cmd.Context(), userFeatureBranch)
+			if err != nil {
+				return err
+			}
+
+			if err := git.AddFeature(cmd.Context(), baseBranch, featureBranch); err != nil {
+				return err
+			}
+
+			output.Printf("%s is based on %s\n", featureBranch, baseBranch)
+
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&baseBranch, "base", "b", "", "base branch")
+
+	return cmd
+}
+
+func makeFeatureListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:         "list",
+		Annotations: map[string]string{annotationReadOnly: "true"},
+		Short:       "show registered feature branches",
+		Aliases:     []string{"l"},
+		Args:        cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, feature := range app.Config.Persistent.FeatureBranches {
+				output.Printf("%s <- %s\n", output.Paint("branch", feature.BranchName), feature.BaseBranch)
+			}
+
+			return nil
+		},
+	}
+}
+
+func makeFeatureRemoveCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove <feature>",
+		Short: "delete review branches of feature branch and forget it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return git.RemoveFeature(cmd.Context(), args[0])
+		},
+	}
+}
+
+func makeFeatureRenameCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rename <old> <new>",
+		Short: "move config and review branches to renamed feature branch",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return git.RenameFeature(cmd.Context(), args[0], args[1])
+		},
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/waffleboot/giiter/internal/git"
+	"github.com/waffleboot/giiter/internal/output"
+)
+
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+	OutputYAML = "yaml"
+
+	// ListSchemaVersion меняется при несовместимом изменении структуры вывода list
+	ListSchemaVersion = 1
+)
+
+type listOutput struct {
+	SchemaVersion int            `json:"schema_version" yaml:"schema_version"`
+	BaseBranch    string         `json:"base_branch" yaml:"base_branch"`
+	FeatureBranch string         `json:"feature_branch" yaml:"feature_branch"`
+	Records       []recordOutput `json:"records" yaml:"records"`
+}
+
+type recordOutput struct {
+	Position       int      `json:"position" yaml:"position"`
+	Status         string   `json:"status" yaml:"status"`
+	FeatureSHA     string   `json:"feature_sha,omitempty" yaml:"feature_sha,omitempty"`
+	ReviewSHA      string   `json:"review_sha,omitempty" yaml:"review_sha,omitempty"`
+	ReviewBranches []string `json:"review_branches" yaml:"review_branches"`
+	NewID          int      `json:"new_id,omitempty" yaml:"new_id,omitempty"`
+	Subject        string   `json:"subject" yaml:"subject"`
+	Body           string   `json:"body,omitempty" yaml:"body,omitempty"`
+	DiffHash       string   `json:"diff_hash,omitempty" yaml:"diff_hash,omitempty"`
+	SkipReason     string   `json:"skip_reason,omitempty" yaml:"skip_reason,omitempty"`
+	Merge          bool     `json:"merge,omitempty" yaml:"merge,omitempty"`
+}
+
+func makeListOutput(ctx context.Context, baseBranch, featureBranch string, records []git.Record) (*listOutput, error) {
+	output := &listOutput{
+		SchemaVersion: ListSchemaVersion,
+		BaseBranch:    baseBranch,
+		FeatureBranch: featureBranch,
+		Records:       make([]recordOutput, 0, len(records)),
+	}
+
+	for i := range records {
+		record := &records[i]
+
+		diffHash, err := git.DiffHash(ctx, record)
+		if err != nil {
+			return nil, err
+		}
+
+		output.Records = append(output.Records, recordOutput{
+			Position:       i + 1,
+			Status:         record.Status(),
+			FeatureSHA:     record.FeatureSHA(),
+			ReviewSHA:      record.ReviewSHA(),
+			ReviewBranches: record.ReviewBranchNames(),
+			NewID:          record.NewID,
+			Subject:        record.CommitMessage().Subject,
+			Body:           record.CommitMessage().Description,
+			DiffHash:       diffHash,
+			SkipReason:     record.SkipReason(),
+			Merge:          record.IsMergeCommit(),
+		})
+	}
+
+	return output, nil
+}
+
+// checkOutputFormat проверяет --output до того, как list начнет читать состояние веток
+func checkOutputFormat(format string) error {
+	switch format {
+	case OutputText, OutputJSON, OutputYAML:
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %s, use %s, %s or %s", format, OutputText, OutputJSON, OutputYAML)
+	}
+}
+
+func printStructured(format string, value interface{}) error {
+	return encodeStructured(output.Writer(), format, value)
+}
+
+func encodeStructured(w io.Writer, format string, value interface{}) error {
+	switch format {
+	case OutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(value)
+	case OutputYAML:
+		enc := yaml.NewEncoder(w)
+		if err := enc.Encode(value); err != nil {
+			return err
+		}
+
+		return enc.Close()
+	default:
+		return checkOutputFormat(format)
+	}
+}
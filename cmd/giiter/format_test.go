@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestListOutputSchema фиксирует схему list -o json и -o yaml, при ее изменении нужно поднять ListSchemaVersion
+func TestListOutputSchema(t *testing.T) {
+	value := &listOutput{
+		SchemaVersion: ListSchemaVersion,
+		BaseBranch:    "master",
+		FeatureBranch: "fa",
+		Records: []recordOutput{
+			{
+				Position:       1,
+				Status:         "matched",
+				FeatureSHA:     "1111111",
+				ReviewSHA:      "1111111",
+				ReviewBranches: []string{"review/fa/1"},
+				Subject:        "parser: add lexer",
+				Body:           "lexer body",
+				DiffHash:       "abc",
+			},
+			{
+				Position:       2,
+				Status:         "new",
+				FeatureSHA:     "2222222",
+				ReviewBranches: []string{},
+				NewID:          2,
+				Subject:        "merge master",
+				Merge:          true,
+			},
+			{
+				Position:       3,
+				Status:         "skipped",
+				FeatureSHA:     "3333333",
+				ReviewBranches: []string{},
+				Subject:        "fixup! parser",
+				SkipReason:     "fixup",
+			},
+		},
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: OutputJSON,
+			want: `{
+  "schema_version": 1,
+  "base_branch": "master",
+  "feature_branch": "fa",
+  "records": [
+    {
+      "position": 1,
+      "status": "matched",
+      "feature_sha": "1111111",
+      "review_sha": "1111111",
+      "review_branches": [
+        "review/fa/1"
+      ],
+      "subject": "parser: add lexer",
+      "body": "lexer body",
+      "diff_hash": "abc"
+    },
+    {
+      "position": 2,
+      "status": "new",
+      "feature_sha": "2222222",
+      "review_branches": [],
+      "new_id": 2,
+      "subject": "merge master",
+      "merge": true
+    },
+    {
+      "position": 3,
+      "status": "skipped",
+      "feature_sha": "3333333",
+      "review_branches": [],
+      "subject": "fixup! parser",
+      "skip_reason": "fixup"
+    }
+  ]
+}
+`,
+		},
+		{
+			format: OutputYAML,
+			want: `schema_version: 1
+base_branch: master
+feature_branch: fa
+records:
+- position: 1
+  status: matched
+  feature_sha: "1111111"
+  review_sha: "1111111"
+  review_branches:
+  - review/fa/1
+  subject: 'parser: add lexer'
+  body: lexer body
+  diff_hash: abc
+- position: 2
+  status: new
+  feature_sha: "2222222"
+  review_branches: []
+  new_id: 2
+  subject: merge master
+  merge: true
+- position: 3
+  status: skipped
+  feature_sha: "3333333"
+  review_branches: []
+  subject: fixup! parser
+  skip_reason: fixup
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			require.NoError(t, encodeStructured(&buf, tt.format, value))
+			require.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestCheckOutputFormat(t *testing.T) {
+	require.NoError(t, checkOutputFormat(OutputText))
+	require.NoError(t, checkOutputFormat(OutputJSON))
+	require.NoError(t, checkOutputFormat(OutputYAML))
+	require.EqualError(t, checkOutputFormat("xml"), "unknown output format xml, use text, json or yaml")
+}
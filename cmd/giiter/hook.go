@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/waffleboot/giiter/internal/git"
+	"github.com/waffleboot/giiter/internal/output"
+)
+
+func makeHookCommand() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "hook",
+		Short: "install commit-msg hook adding Change-Id trailer",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			hook, err := git.InstallChangeIDHook(cmd.Context(), force)
+			if err != nil {
+				return err
+			}
+
+			output.Printf("installed %s, use --change-id to match review branches by Change-Id\n", hook)
+
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&force, "force", false, "overwrite existing commit-msg hook")
+
+	return cmd
+}
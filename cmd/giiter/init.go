@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/waffleboot/giiter/internal/app"
+	"github.com/waffleboot/giiter/internal/output"
+)
+
+func makeInitCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "init",
+		Short: "create config file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			created, err := app.InitConfig(_cfgFile)
+			if err != nil {
+				return err
+			}
+
+			if !created {
+				output.Printf("%s already exists\n", _cfgFile)
+
+				return nil
+			}
+
+			output.Printf("created %s, register feature branches with giiter feature add\n", _cfgFile)
+
+			return nil
+		},
+	}
+}
@@ -5,13 +5,19 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
+	"github.com/waffleboot/giiter/internal/app"
 	"github.com/waffleboot/giiter/internal/git"
+	"github.com/waffleboot/giiter/internal/output"
 )
 
 type listCommand struct {
-	config *git.Config
+	config    *git.Config
+	all       bool
+	output    string
+	interdiff bool
 }
 
 func makeListCommand(config *git.Config) *cobra.Command {
@@ -19,32 +25,154 @@ func makeListCommand(config *git.Config) *cobra.Command {
 		config: config,
 	}
 
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:     "list",
 		Short:   "show feature commits",
 		Aliases: []string{"l"},
-		// PersistentPreRunE не нужен, см. main
-		RunE: c.run,
+		// list можно смотреть, пока другой giiter меняет ветки
+		Annotations: map[string]string{annotationReadOnly: "true"},
+		RunE:        c.run,
 	}
+
+	// при --all показываются все feature ветки, текущая ветка не проверяется
+	addCommonFlags(cmd, config)
+
+	validate := cmd.PersistentPreRunE
+	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if c.all {
+			return parentPersistentPreRunE(cmd, args)
+		}
+
+		return validate(cmd, args)
+	}
+
+	cmd.Flags().BoolVarP(&c.all, "all", "a", false, "show all feature branches as a stack tree")
+	cmd.Flags().StringVarP(&c.output, "output", "o", OutputText, "output format: text, json or yaml")
+	cmd.Flags().BoolVar(&c.interdiff, "interdiff", false, "show whether switching records change review branches content")
+
+	return cmd
 }
 
+// ключи меток и цветов записей, см. marker.<ключ> и color.<ключ> в giiter config list
 const (
-	Yellow = "\033[33m"
-	Green  = "\033[32m"
-	Reset  = "\033[0m"
-	Red    = "\033[31m"
-
-	MarkSwitchCommit = Yellow + "**" + Reset
-	MarkNewCommit    = Yellow + "++" + Reset
-	MarkOldCommit    = Red + "--" + Reset
-	MarkOkCommit     = Green + "ok" + Reset
+	markNew    = "new"
+	markOld    = "old"
+	markOk     = "ok"
+	markSwitch = "switch"
+	markMerge  = "merge"
+	markSkip   = "skip"
 )
 
+// markOf возвращает ключ метки записи по ее статусу
+func markOf(record *git.Record) string {
+	switch record.Status() {
+	case git.StatusSkipped:
+		return markSkip
+	case git.StatusNew:
+		return markNew
+	case git.StatusOld:
+		return markOld
+	case git.StatusMatched:
+		return markOk
+	default:
+		return markSwitch
+	}
+}
+
 func (c *listCommand) run(cmd *cobra.Command, args []string) error {
-	return listFeatureCommits(cmd.Context(), c.config)
+	if err := checkOutputFormat(c.output); err != nil {
+		return err
+	}
+
+	if c.all {
+		if c.output != OutputText {
+			return errors.New("--output is not supported with --all")
+		}
+
+		return listFeatureTree(cmd.Context())
+	}
+
+	if c.output != OutputText {
+		return printFeatureCommits(cmd.Context(), c.config, c.output)
+	}
+
+	return listFeatureCommits(cmd.Context(), c.config, c.interdiff)
 }
 
-func listFeatureCommits(ctx context.Context, c *git.Config) error {
+func printFeatureCommits(ctx context.Context, c *git.Config, format string) error {
+	baseBranch, featureBranch, err := c.Branches()
+	if err != nil {
+		return err
+	}
+
+	records, err := git.State(ctx, baseBranch, featureBranch)
+	if err != nil {
+		return err
+	}
+
+	result, err := makeListOutput(ctx, baseBranch, featureBranch, records)
+	if err != nil {
+		return err
+	}
+
+	return printStructured(format, result)
+}
+
+func listFeatureTree(ctx context.Context) error {
+	roots := git.FeatureTree()
+	if len(roots) == 0 {
+		output.Println("no feature branches")
+
+		return nil
+	}
+
+	for _, root := range roots {
+		output.Println(root.BaseBranch)
+
+		if err := printFeature(ctx, root, 1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func printFeature(ctx context.Context, feature *git.Feature, depth int) error {
+	records, err := git.State(ctx, feature.BaseBranch, feature.BranchName)
+	if err != nil {
+		return errors.WithMessagef(err, "feature %s", feature.BranchName)
+	}
+
+	var commits, reviews int
+
+	for i := range records {
+		if records[i].IsSkipped() || records[i].IsOldCommit() {
+			continue
+		}
+
+		commits++
+
+		if records[i].HasReview() {
+			reviews++
+		}
+	}
+
+	output.Printf("%s%s (%d commits, %d reviews)\n",
+		strings.Repeat("  ", depth),
+		output.Paint("branch", feature.BranchName),
+		commits,
+		reviews)
+
+	for _, child := range feature.Children {
+		if err := printFeature(ctx, child, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func listFeatureCommits(ctx context.Context, c *git.Config, interdiff bool) error {
 	baseBranch, featureBranch, err := c.Branches()
 	if err != nil {
 		return err
@@ -59,35 +187,74 @@ func listFeatureCommits(ctx context.Context, c *git.Config) error {
 		record := records[i]
 
 		commitSHA := record.CommitSHA()
-		commitMsg := Yellow + record.CommitMessage().Subject + Reset
+		commitMsg := output.Paint("subject", record.CommitMessage().Subject)
+		if record.IsMergeCommit() {
+			commitMsg = output.Marker(markMerge) + " " + commitMsg
+		}
+
 		reviewBranches := strings.Join(record.ReviewBranchNamesForUI(), ",")
 
 		switch {
+		case record.IsSkipped():
+			output.Printf("%d) %s\n", i+1,
+				output.Paint(markSkip, fmt.Sprintf("%s %s (%s) %s",
+					app.Config.Markers[markSkip],
+					commitSHA,
+					record.SkipReason(),
+					record.CommitMessage().Subject)))
 		case record.IsNewCommit():
-			fmt.Printf("%d) %s %s %s\n", i+1,
-				MarkNewCommit,
+			output.Printf("%d) %s %s %s\n", i+1,
+				output.Marker(markNew),
 				commitSHA,
 				commitMsg)
 		case record.IsOldCommit():
-			fmt.Printf("%d) %s %s [%s] %s\n", i+1,
-				MarkOldCommit,
+			output.Printf("%d) %s %s [%s] %s\n", i+1,
+				output.Marker(markOld),
 				commitSHA,
 				reviewBranches,
 				commitMsg)
 		case record.MatchedCommit():
-			fmt.Printf("%d) %s %s [%s] %s\n", i+1,
-				MarkOkCommit,
+			output.Printf("%d) %s %s [%s] %s\n", i+1,
+				output.Marker(markOk),
 				commitSHA,
 				reviewBranches,
 				commitMsg)
 		default:
-			fmt.Printf("%d) %s %s [%s] %s\n", i+1,
-				MarkSwitchCommit,
+			output.Printf("%d) %s %s [%s] %s\n", i+1,
+				output.Marker(markSwitch),
 				commitSHA,
 				reviewBranches,
 				commitMsg)
+
+			if interdiff {
+				if err := printInterdiffStat(ctx, &record); err != nil {
+					return err
+				}
+			}
 		}
 	}
 
 	return nil
 }
+
+// printInterdiffStat показывает под записью **, получит ли ее MR новую версию после make
+func printInterdiffStat(ctx context.Context, record *git.Record) error {
+	rebased, stat, err := git.InterdiffStat(ctx, record)
+	if err != nil {
+		return err
+	}
+
+	if rebased {
+		output.Println("     rebase only, changes are the same as in the review branch")
+
+		return nil
+	}
+
+	output.Println("     " + output.Paint("switch", "content changed since the review branch:"))
+
+	for _, line := range stat {
+		output.Println("    " + line)
+	}
+
+	return nil
+}
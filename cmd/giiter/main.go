@@ -5,15 +5,29 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/waffleboot/giiter/internal/app"
 	"github.com/waffleboot/giiter/internal/git"
+	"github.com/waffleboot/giiter/internal/output"
 )
 
-var _cfgFile string
+var (
+	_cfgFile     string
+	_stateFile   string
+	_pendingFile string
+	_readOnly    bool
+	_lock        *app.Lock
+	_lockWait    time.Duration
+)
+
+// annotationReadOnly помечает команды, которые не меняют состояние и работают без блокировки,
+// их изменения состояния, например base ветка из -b, действуют только до конца команды
+const annotationReadOnly = "readonly"
 
 func main() {
 	if err := run(); err != nil {
@@ -26,8 +40,6 @@ func run() error {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	cobra.OnInitialize(initConfig)
-
 	rootCmd := makeRootCommand()
 
 	config := new(git.Config)
@@ -37,35 +49,149 @@ func run() error {
 	diffCmd := makeDiffCommand(config)
 	assignCmd := makeAssignCommand(config)
 	rebaseCmd := makeRebaseCommand(config)
+	editCmd := makeEditCommand(config)
+	moveCmd := makeMoveCommand(config)
+	dropCmd := makeDropCommand(config)
+	squashCmd := makeSquashCommand(config)
+	splitCmd := makeSplitCommand(config)
+	absorbCmd := makeAbsorbCommand(config)
+	uiCmd := makeUICommand(config)
+	readyCmd := makeReadyCommand(config)
+	draftCmd := makeDraftCommand(config)
 
-	addCommonFlags(listCmd, config)
 	addCommonFlags(makeCmd, config)
 	addCommonFlags(diffCmd, config)
-	addCommonFlags(rebaseCmd, config)
 	addCommonFlags(assignCmd, config)
+	addCommonFlags(editCmd, config)
+	addCommonFlags(moveCmd, config)
+	addCommonFlags(dropCmd, config)
+	addCommonFlags(squashCmd, config)
+	addCommonFlags(splitCmd, config)
+	addCommonFlags(absorbCmd, config)
+	addCommonFlags(uiCmd, config)
+	addCommonFlags(readyCmd, config)
+	addCommonFlags(draftCmd, config)
 
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(diffCmd)
 	rootCmd.AddCommand(makeCmd)
 	rootCmd.AddCommand(assignCmd)
 	rootCmd.AddCommand(rebaseCmd)
+	rootCmd.AddCommand(editCmd)
+	rootCmd.AddCommand(moveCmd)
+	rootCmd.AddCommand(dropCmd)
+	rootCmd.AddCommand(squashCmd)
+	rootCmd.AddCommand(splitCmd)
+	rootCmd.AddCommand(absorbCmd)
+	rootCmd.AddCommand(uiCmd)
+	rootCmd.AddCommand(readyCmd)
+	rootCmd.AddCommand(draftCmd)
+	rootCmd.AddCommand(makeContinueCommand(config))
+	rootCmd.AddCommand(makeAbortCommand())
 	rootCmd.AddCommand(makeDeleteCommand(config))
 	rootCmd.AddCommand(makeBranchesCommand(config))
+	rootCmd.AddCommand(makeHookCommand())
+	rootCmd.AddCommand(makeInitCommand())
+	rootCmd.AddCommand(makeFeatureCommand())
+	rootCmd.AddCommand(makeConfigCommand())
 
-	return rootCmd.ExecuteContext(ctx)
+	errExecute := rootCmd.ExecuteContext(ctx)
+
+	if !_readOnly {
+		if err := app.SaveConfig(_cfgFile, _stateFile, _pendingFile); err != nil && errExecute == nil {
+			errExecute = err
+		}
+	}
+
+	if err := _lock.Release(); err != nil && errExecute == nil {
+		errExecute = err
+	}
+
+	return errExecute
 }
 
-func initConfig() {
-	if err := app.LoadConfig(_cfgFile); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+// initConfig блокирует репозиторий для изменяющих команд и загружает настройки и состояние
+func initConfig(cmd *cobra.Command) error {
+	// файл репозитория ищется от корня рабочей копии, а не от текущего каталога
+	if !filepath.IsAbs(_cfgFile) {
+		if topLevel, err := git.TopLevel(context.Background()); err == nil {
+			_cfgFile = filepath.Join(topLevel, _cfgFile)
+		}
+	}
+
+	// вне репозитория состояния нет, но настройки доступны
+	if stateFile, err := git.StateFile(context.Background()); err == nil {
+		_stateFile = stateFile
+	}
+
+	if pendingFile, err := git.PendingFile(context.Background()); err == nil {
+		_pendingFile = pendingFile
+	}
+
+	_readOnly = cmd.Annotations[annotationReadOnly] != ""
+
+	if _stateFile != "" && !_readOnly {
+		lock, err := acquireLock()
+		if err != nil {
+			return err
+		}
+
+		_lock = lock
+	}
+
+	migrated, err := app.LoadConfig(_cfgFile, _stateFile, _pendingFile)
+	if err != nil {
+		return err
 	}
+
+	if migrated {
+		output.Infof("moved state from %s to %s\n", _cfgFile, _stateFile)
+	}
+
+	return nil
+}
+
+func acquireLock() (*app.Lock, error) {
+	return app.AcquireLock(filepath.Join(filepath.Dir(_stateFile), "lock"), _lockWait)
+}
+
+// withLock выполняет изменяющее действие команды только для чтения, например ui, под блокировкой
+// репозитория: состояние перечитывается, чтобы не затереть изменения других giiter, и сразу сохраняется
+func withLock(action func() error) error {
+	if _stateFile == "" {
+		return action()
+	}
+
+	lock, err := acquireLock()
+	if err != nil {
+		return err
+	}
+
+	errAction := func() error {
+		if _, err := app.LoadConfig(_cfgFile, _stateFile, _pendingFile); err != nil {
+			return err
+		}
+
+		errAction := action()
+
+		if err := app.SaveConfig(_cfgFile, _stateFile, _pendingFile); err != nil && errAction == nil {
+			errAction = err
+		}
+
+		return errAction
+	}()
+
+	if err := lock.Release(); err != nil && errAction == nil {
+		errAction = err
+	}
+
+	return errAction
 }
 
 func parentPersistentPreRunE(cmd *cobra.Command, args []string) error {
 	for p := cmd.Parent(); p != nil; p = p.Parent() {
 		if p.PersistentPreRunE != nil {
-			return p.PersistentPreRunE(p, args)
+			return p.PersistentPreRunE(cmd, args)
 		}
 	}
 
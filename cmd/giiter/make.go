@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -11,6 +12,10 @@ import (
 
 type makeCommand struct {
 	config *git.Config
+	// mrOptions метаданные MR из флагов, дополняют настройки merge_requests из .giiter.yml
+	mrOptions          app.MergeRequestOptions
+	removeSourceBranch bool
+	squash             bool
 }
 
 func makeMakeCommand(config *git.Config) *cobra.Command {
@@ -25,6 +30,13 @@ func makeMakeCommand(config *git.Config) *cobra.Command {
 		RunE: c.run,
 	}
 	cmd.Flags().StringVarP(&app.Config.MergeRequestPrefix, "prefix", "t", "", "title prefix for merge request")
+	cmd.Flags().BoolVar(&app.Config.MergeRequestDraft, "draft", true, "create merge requests as drafts")
+	cmd.Flags().StringSliceVar(&c.mrOptions.Labels, "label", nil, "merge request label, can be repeated")
+	cmd.Flags().StringSliceVar(&c.mrOptions.Assignees, "assignee", nil, "merge request assignee username, can be repeated")
+	cmd.Flags().StringSliceVar(&c.mrOptions.Reviewers, "reviewer", nil, "merge request reviewer username, can be repeated")
+	cmd.Flags().StringVar(&c.mrOptions.Milestone, "milestone", "", "merge request milestone")
+	cmd.Flags().BoolVar(&c.removeSourceBranch, "remove-source-branch", false, "remove review branch after merge")
+	cmd.Flags().BoolVar(&c.squash, "squash", false, "squash commits on merge")
 
 	return cmd
 }
@@ -35,14 +47,40 @@ func (c *makeCommand) run(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	records, err := git.Refresh(cmd.Context(), baseBranch, featureBranch)
+	// только явно заданный флаг заменяет настройку из .giiter.yml, в том числе на false
+	if cmd.Flags().Changed("remove-source-branch") {
+		c.mrOptions.RemoveSourceBranch = &c.removeSourceBranch
+	}
+
+	if cmd.Flags().Changed("squash") {
+		c.mrOptions.Squash = &c.squash
+	}
+
+	if err := makeReviewBranches(cmd.Context(), baseBranch, featureBranch, c.mrOptions); err != nil {
+		return err
+	}
+
+	return listFeatureCommits(cmd.Context(), c.config, false)
+}
+
+// makeReviewBranches создает review ветки и MR для новых записей, flags метаданные MR из флагов make
+func makeReviewBranches(ctx context.Context, baseBranch, featureBranch string, flags app.MergeRequestOptions) error {
+	records, err := git.Refresh(ctx, baseBranch, featureBranch)
 	if err != nil {
 		return err
 	}
 
-	prevBranch := baseBranch
+	// у feature ветки, основанной на другой feature ветке, первый MR смотрит на ее последнюю review ветку
+	prevBranch, err := git.StackBase(ctx, baseBranch)
+	if err != nil {
+		return err
+	}
 
 	for i := range records {
+		if records[i].IsSkipped() {
+			continue
+		}
+
 		if records[i].HasReview() {
 			prevBranch, err = records[i].AnyReviewBranch()
 			if err != nil {
@@ -52,17 +90,10 @@ func (c *makeCommand) run(cmd *cobra.Command, args []string) error {
 			continue
 		}
 
-		newBranch := fmt.Sprintf(git.Prefix+"%s/%d", featureBranch, records[i].NewID)
-
-		title := "Draft: "
-		if app.Config.MergeRequestPrefix != "" {
-			title += app.Config.MergeRequestPrefix + ": "
-		}
-
-		title += records[i].CommitMessage().Subject
+		newBranch := git.ReviewBranchName(featureBranch, records[i].NewID)
 
 		if err := git.CreateBranch(
-			cmd.Context(),
+			ctx,
 			git.Branch{
 				CommitSHA:  records[i].CommitSHA(),
 				BranchName: newBranch,
@@ -70,13 +101,18 @@ func (c *makeCommand) run(cmd *cobra.Command, args []string) error {
 			return err
 		}
 
+		if app.Config.UseChangeID {
+			git.RememberChangeID(featureBranch, records[i].ChangeID(), newBranch)
+		}
+
 		if err := git.CreateMergeRequest(
-			cmd.Context(),
+			ctx,
 			git.MergeRequest{
-				Title:        title,
+				Title:        git.MergeRequestTitle(records[i].CommitMessage().Subject),
 				SourceBranch: newBranch,
 				TargetBranch: prevBranch,
 				Description:  records[i].CommitMessage().Description,
+				Options:      git.MergeRequestOptions(featureBranch, flags, records[i].CommitMessage()),
 			}); err != nil {
 			return err
 		}
@@ -84,5 +120,5 @@ func (c *makeCommand) run(cmd *cobra.Command, args []string) error {
 		prevBranch = newBranch
 	}
 
-	return listFeatureCommits(cmd.Context(), c.config)
+	return nil
 }
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/waffleboot/giiter/internal/git"
+	"github.com/waffleboot/giiter/internal/output"
+)
+
+type draftCommand struct {
+	config *git.Config
+	draft  bool
+}
+
+func makeReadyCommand(config *git.Config) *cobra.Command {
+	c := draftCommand{
+		config: config,
+	}
+
+	return &cobra.Command{
+		Use:   "ready <record>...",
+		Short: "mark merge requests ready for review",
+		Args:  cobra.MinimumNArgs(1),
+		// PersistentPreRunE не нужен, см. main
+		RunE: c.run,
+	}
+}
+
+func makeDraftCommand(config *git.Config) *cobra.Command {
+	c := draftCommand{
+		config: config,
+		draft:  true,
+	}
+
+	return &cobra.Command{
+		Use:   "draft <record>...",
+		Short: "mark merge requests as drafts",
+		Args:  cobra.MinimumNArgs(1),
+		// PersistentPreRunE не нужен, см. main
+		RunE: c.run,
+	}
+}
+
+func (c *draftCommand) run(cmd *cobra.Command, args []string) error {
+	baseBranch, featureBranch, err := c.config.Branches()
+	if err != nil {
+		return err
+	}
+
+	records, err := git.State(cmd.Context(), baseBranch, featureBranch)
+	if err != nil {
+		return err
+	}
+
+	var indexes []int
+
+	for _, selector := range args {
+		selected, err := git.Select(records, selector)
+		if err != nil {
+			return err
+		}
+
+		indexes = append(indexes, selected...)
+	}
+
+	for _, i := range indexes {
+		if !records[i].HasReview() {
+			return fmt.Errorf("record %d has no review branch, run make first", i+1)
+		}
+	}
+
+	for _, i := range indexes {
+		if err := setDraft(cmd.Context(), &records[i], c.draft); err != nil {
+			return fmt.Errorf("record %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// setDraft меняет состояние MR записи и сообщает о результате
+func setDraft(ctx context.Context, record *git.Record, draft bool) error {
+	branch, err := record.AnyReviewBranch()
+	if err != nil {
+		return err
+	}
+
+	changed, err := git.SetDraft(ctx, branch, draft)
+	if err != nil {
+		return err
+	}
+
+	state := "ready"
+	if draft {
+		state = "draft"
+	}
+
+	if !changed {
+		output.Printf("%s is already %s\n", output.Paint("branch", branch), state)
+
+		return nil
+	}
+
+	output.Printf("%s is %s now\n", output.Paint("branch", branch), state)
+
+	return nil
+}
@@ -1,13 +1,22 @@
 package main
 
 import (
+	"context"
+
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
+	"github.com/waffleboot/giiter/internal/app"
 	"github.com/waffleboot/giiter/internal/git"
+	"github.com/waffleboot/giiter/internal/output"
 )
 
 type rebaseCommand struct {
-	config *git.Config
+	config    *git.Config
+	resumable bool
+	fetch     bool
+	cont      bool
+	abort     bool
 }
 
 func makeRebaseCommand(config *git.Config) *cobra.Command {
@@ -15,20 +24,110 @@ func makeRebaseCommand(config *git.Config) *cobra.Command {
 		config: config,
 	}
 
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:     "rebase",
 		Short:   "rebase feature branch",
 		Aliases: []string{"r"},
-		// PersistentPreRunE не нужен, см. main
-		RunE: c.run,
+		RunE:    c.run,
+	}
+
+	// при --continue и --abort ветки берутся из незавершенной операции,
+	// а HEAD во время rebase отсоединен, поэтому ветки не проверяются
+	addCommonFlags(cmd, config)
+
+	validate := cmd.PersistentPreRunE
+	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if c.cont || c.abort {
+			return parentPersistentPreRunE(cmd, args)
+		}
+
+		return validate(cmd, args)
 	}
+
+	cmd.Flags().BoolVar(&c.resumable, "resumable", false, "leave rebase in progress on conflict")
+	cmd.Flags().BoolVar(&c.fetch, "fetch", false, "fetch base branch from origin and rebase onto its fork point")
+	cmd.Flags().BoolVar(&c.cont, "continue", false, "continue rebase and refresh review branches")
+	cmd.Flags().BoolVar(&c.abort, "abort", false, "abort rebase and restore feature branch")
+
+	return cmd
 }
 
 func (c *rebaseCommand) run(cmd *cobra.Command, args []string) error {
+	switch {
+	case c.cont && c.abort:
+		return errors.New("--continue and --abort are mutually exclusive")
+	case c.cont:
+		return continueOperation(cmd, c.config)
+	case c.abort:
+		return git.Abort(cmd.Context())
+	}
+
 	baseBranch, featureBranch, err := c.config.Branches()
 	if err != nil {
 		return err
 	}
 
+	if c.fetch {
+		report, err := git.FetchRebase(cmd.Context(), baseBranch, featureBranch, c.resumable)
+		if report != nil {
+			printRebaseReport(report)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if app.Config.MergeRequestAutoReady && len(report.Landed) > 0 && report.Conflict == nil {
+			markBottomReady(cmd.Context(), baseBranch, featureBranch)
+		}
+
+		return nil
+	}
+
+	if c.resumable {
+		return git.StartRebase(cmd.Context(), baseBranch, featureBranch)
+	}
+
 	return git.Rebase(cmd.Context(), baseBranch, featureBranch)
 }
+
+func printRebaseReport(report *git.RebaseReport) {
+	for i := range report.Landed {
+		output.Printf("%s %s %s\n", output.Paint("landed", "landed "), report.Landed[i].CommitSHA(), report.Landed[i].CommitMessage().Subject)
+	}
+
+	for i := range report.Rebased {
+		output.Printf("%s %s %s\n", output.Paint("rebased", "rebased"), report.Rebased[i].CommitSHA(), report.Rebased[i].CommitMessage().Subject)
+	}
+
+	if report.Conflict != nil {
+		output.Printf("%s %s %s\n", output.Paint("conflict", "conflict"), report.Conflict.CommitSHA(), report.Conflict.CommitMessage().Subject)
+	}
+}
+
+// markBottomReady снимает draft с нижнего MR feature ветки, когда MR под ним влиты,
+// rebase к этому моменту уже выполнен, поэтому ошибки только выводятся
+func markBottomReady(ctx context.Context, baseBranch, featureBranch string) {
+	records, err := git.State(ctx, baseBranch, featureBranch)
+	if err != nil {
+		output.Warnf("could not mark bottom merge request ready: %v", err)
+
+		return
+	}
+
+	for i := range records {
+		if records[i].IsSkipped() || records[i].IsOldCommit() {
+			continue
+		}
+
+		if !records[i].HasReview() {
+			return
+		}
+
+		if err := setDraft(ctx, &records[i], false); err != nil {
+			output.Warnf("could not mark bottom merge request ready: %v", err)
+		}
+
+		return
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/waffleboot/giiter/internal/git"
+)
+
+// rewriteCommand перестраивает feature ветку по записям, выбранным селекторами
+type rewriteCommand struct {
+	config  *git.Config
+	rewrite func(cmd *cobra.Command, baseBranch, featureBranch string, records []git.Record, positions []int) error
+	// multi разрешает явные диапазоны 2..4, остальные селекторы всегда выбирают одну запись
+	multi bool
+}
+
+func makeMoveCommand(config *git.Config) *cobra.Command {
+	c := rewriteCommand{
+		config: config,
+		rewrite: func(cmd *cobra.Command, baseBranch, featureBranch string, records []git.Record, positions []int) error {
+			return git.Move(cmd.Context(), baseBranch, featureBranch, records, positions[0], positions[1])
+		},
+	}
+
+	return &cobra.Command{
+		Use:   "move <record> <to>",
+		Short: "move record to another position in feature branch",
+		Args:  cobra.ExactArgs(2),
+		// PersistentPreRunE не нужен, см. main
+		RunE: c.run,
+	}
+}
+
+func makeDropCommand(config *git.Config) *cobra.Command {
+	c := rewriteCommand{
+		config: config,
+		rewrite: func(cmd *cobra.Command, baseBranch, featureBranch string, records []git.Record, positions []int) error {
+			return git.Drop(cmd.Context(), baseBranch, featureBranch, records, positions...)
+		},
+		multi: true,
+	}
+
+	return &cobra.Command{
+		Use:   "drop <record>...",
+		Short: "drop records from feature branch",
+		Args:  cobra.MinimumNArgs(1),
+		// PersistentPreRunE не нужен, см. main
+		RunE: c.run,
+	}
+}
+
+func makeSquashCommand(config *git.Config) *cobra.Command {
+	c := rewriteCommand{
+		config: config,
+		rewrite: func(cmd *cobra.Command, baseBranch, featureBranch string, records []git.Record, positions []int) error {
+			return git.Squash(cmd.Context(), baseBranch, featureBranch, records, positions[0], positions[1])
+		},
+	}
+
+	return &cobra.Command{
+		Use:   "squash <record> <into>",
+		Short: "squash record into another record keeping its message",
+		Args:  cobra.ExactArgs(2),
+		// PersistentPreRunE не нужен, см. main
+		RunE: c.run,
+	}
+}
+
+func (c *rewriteCommand) run(cmd *cobra.Command, args []string) error {
+	baseBranch, featureBranch, err := c.config.Branches()
+	if err != nil {
+		return err
+	}
+
+	records, err := git.State(cmd.Context(), baseBranch, featureBranch)
+	if err != nil {
+		return err
+	}
+
+	positions := make([]int, 0, len(args))
+
+	for _, arg := range args {
+		if c.multi {
+			indexes, err := git.SelectRange(records, arg)
+			if err != nil {
+				return err
+			}
+
+			positions = append(positions, indexes...)
+
+			continue
+		}
+
+		index, err := recordIndex(records, arg)
+		if err != nil {
+			return err
+		}
+
+		positions = append(positions, index)
+	}
+
+	if err := c.rewrite(cmd, baseBranch, featureBranch, records, positions); err != nil {
+		return err
+	}
+
+	return refreshFeatureCommits(cmd, c.config)
+}
@@ -1,12 +1,10 @@
 package main
 
 import (
-	"os"
-
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v2"
 
 	"github.com/waffleboot/giiter/internal/app"
+	"github.com/waffleboot/giiter/internal/output"
 )
 
 func makeRootCommand() *cobra.Command {
@@ -14,27 +12,35 @@ func makeRootCommand() *cobra.Command {
 		Use:           "giiter",
 		SilenceUsage:  true,
 		SilenceErrors: true,
-		PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
-			f, err := os.OpenFile(_cfgFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
-			if err != nil {
+		// cmd это выполняемая команда, ее флаги перекрывают остальные слои конфигурации
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := initConfig(cmd); err != nil {
 				return err
 			}
-			defer f.Close()
 
-			enc := yaml.NewEncoder(f)
-			if err := enc.Encode(app.Config.Persistent); err != nil {
+			err := app.ApplySettings(func(name string) bool {
+				flag := cmd.Flags().Lookup(name)
+
+				return flag != nil && flag.Changed
+			})
+			if err != nil {
 				return err
 			}
 
+			output.Setup()
+
 			return nil
 		},
 	}
 
 	cmd.PersistentFlags().StringVar(&_cfgFile, "config", ".giiter.yml", "config file")
+	cmd.PersistentFlags().DurationVar(&_lockWait, "wait", 0, "wait for repository lock held by another giiter")
+	cmd.PersistentFlags().StringVar(&app.Config.Color, "color", output.ColorAuto, "colorize output: auto, always or never")
 	cmd.PersistentFlags().BoolVarP(&app.Config.Debug, "debug", "d", false, "debug output")
 	cmd.PersistentFlags().BoolVarP(&app.Config.Verbose, "verbose", "v", false, "verbose output")
 	cmd.PersistentFlags().BoolVarP(&app.Config.EnableGitPush, "push", "p", false, "enable git push")
 	cmd.PersistentFlags().BoolVar(&app.Config.UseSubjectToMatch, "subj", false, "use commit subject to match")
+	cmd.PersistentFlags().BoolVar(&app.Config.UseChangeID, "change-id", false, "use Change-Id commit trailer to match")
 
 	return cmd
 }
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/waffleboot/giiter/internal/git"
+	"github.com/waffleboot/giiter/internal/output"
+)
+
+type splitCommand struct {
+	config *git.Config
+}
+
+func makeSplitCommand(config *git.Config) *cobra.Command {
+	c := splitCommand{
+		config: config,
+	}
+
+	return &cobra.Command{
+		Use:   "split <record> [<path>...]",
+		Short: "split record into two review branches, paths select the first part",
+		Args:  cobra.MinimumNArgs(1),
+		// PersistentPreRunE не нужен, см. main
+		RunE: c.run,
+	}
+}
+
+func (c *splitCommand) run(cmd *cobra.Command, args []string) error {
+	baseBranch, featureBranch, err := c.config.Branches()
+	if err != nil {
+		return err
+	}
+
+	records, err := git.State(cmd.Context(), baseBranch, featureBranch)
+	if err != nil {
+		return err
+	}
+
+	index, err := recordIndex(records, args[0])
+	if err != nil {
+		return err
+	}
+
+	stopped, err := git.Split(cmd.Context(), baseBranch, featureBranch, records, index, args[1:])
+	if err != nil {
+		return err
+	}
+
+	if stopped {
+		output.Printf("split %s %s\n", records[index].CommitSHA(), records[index].CommitMessage().Subject)
+		output.Println("stage the first part and run giiter continue, or giiter abort")
+
+		return nil
+	}
+
+	return refreshFeatureCommits(cmd, c.config)
+}
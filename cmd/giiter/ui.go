@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/waffleboot/giiter/internal/app"
+	"github.com/waffleboot/giiter/internal/git"
+	"github.com/waffleboot/giiter/internal/output"
+	"github.com/waffleboot/giiter/internal/tui"
+)
+
+type uiCommand struct {
+	config  *git.Config
+	records []git.Record
+	model   *tui.Model
+	term    *tui.Terminal
+}
+
+// uiAction изменяющее действие ui, records только что прочитанное состояние feature ветки
+type uiAction func(ctx context.Context, baseBranch, featureBranch string, records []git.Record) error
+
+func makeUICommand(config *git.Config) *cobra.Command {
+	c := uiCommand{
+		config: config,
+	}
+
+	return &cobra.Command{
+		Use:   "ui",
+		Short: "browse and change feature commits in terminal UI",
+		Args:  cobra.NoArgs,
+		// ui открыт долго, поэтому репозиторий блокируется только на время действий, см. execute
+		Annotations: map[string]string{annotationReadOnly: "true"},
+		// PersistentPreRunE не нужен, см. main
+		RunE: c.run,
+	}
+}
+
+func (c *uiCommand) run(cmd *cobra.Command, args []string) error {
+	term, err := tui.OpenTerminal()
+	if err != nil {
+		return err
+	}
+
+	c.term = term
+
+	defer func() {
+		_ = c.term.Leave()
+	}()
+
+	c.model = tui.NewModel(nil)
+
+	if err := c.reload(cmd); err != nil {
+		return err
+	}
+
+	for {
+		c.term.Draw(c.model.View(c.term.Size()))
+
+		keys, err := c.term.ReadKeys()
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			quit, err := c.perform(cmd, c.model.HandleKey(key))
+			if err != nil {
+				return err
+			}
+
+			if quit {
+				return nil
+			}
+		}
+	}
+}
+
+func (c *uiCommand) perform(cmd *cobra.Command, action tui.Action) (bool, error) {
+	switch action.Kind {
+	case tui.ActionQuit:
+		return true, nil
+	case tui.ActionPreview:
+		c.preview(cmd)
+	case tui.ActionOpen:
+		c.open(cmd, action.From)
+	case tui.ActionAssign:
+		return false, c.execute(cmd, func(ctx context.Context, _, _ string, records []git.Record) error {
+			return assignRecord(ctx, records, strconv.Itoa(action.From), strconv.Itoa(action.To))
+		})
+	case tui.ActionMove:
+		return false, c.execute(cmd, func(ctx context.Context, baseBranch, featureBranch string, records []git.Record) error {
+			return git.Move(ctx, baseBranch, featureBranch, records, action.From-1, action.To-1)
+		})
+	case tui.ActionDrop:
+		return false, c.execute(cmd, func(ctx context.Context, baseBranch, featureBranch string, records []git.Record) error {
+			return git.Drop(ctx, baseBranch, featureBranch, records, action.From-1)
+		})
+	case tui.ActionMake:
+		// флагов make у ui нет, метаданные MR берутся из .giiter.yml и trailers
+		return false, c.execute(cmd, func(ctx context.Context, baseBranch, featureBranch string, _ []git.Record) error {
+			return makeReviewBranches(ctx, baseBranch, featureBranch, app.MergeRequestOptions{})
+		})
+	case tui.ActionRefresh:
+		return false, c.execute(cmd, nil)
+	case tui.ActionNone:
+	}
+
+	return false, nil
+}
+
+// execute выполняет действие в обычном режиме терминала, чтобы был виден вывод, и показывает записи после него
+func (c *uiCommand) execute(cmd *cobra.Command, action uiAction) error {
+	if err := c.term.Leave(); err != nil {
+		return err
+	}
+
+	err := withLock(func() error {
+		if action == nil {
+			return refreshFeatureCommits(cmd, c.config)
+		}
+
+		baseBranch, featureBranch, err := c.config.Branches()
+		if err != nil {
+			return err
+		}
+
+		// пока ui был открыт, feature ветку мог изменить другой giiter, тогда позиции записей устарели
+		records, err := git.State(cmd.Context(), baseBranch, featureBranch)
+		if err != nil {
+			return err
+		}
+
+		if !sameRecords(records, c.records) {
+			return errors.New("feature branch changed since last refresh, press r and retry")
+		}
+
+		if err := action(cmd.Context(), baseBranch, featureBranch, records); err != nil {
+			return err
+		}
+
+		return refreshFeatureCommits(cmd, c.config)
+	})
+
+	if err != nil {
+		output.Println(err)
+	}
+
+	if err := c.term.Pause(); err != nil {
+		return err
+	}
+
+	if err := c.term.Enter(); err != nil {
+		return err
+	}
+
+	return c.reload(cmd)
+}
+
+func sameRecords(a, b []git.Record) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].CommitSHA() != b[i].CommitSHA() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c *uiCommand) reload(cmd *cobra.Command) error {
+	baseBranch, featureBranch, err := c.config.Branches()
+	if err != nil {
+		return err
+	}
+
+	c.records, err = git.State(cmd.Context(), baseBranch, featureBranch)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]tui.Row, 0, len(c.records))
+
+	for i := range c.records {
+		record := &c.records[i]
+
+		subject := record.CommitMessage().Subject
+		if record.IsMergeCommit() {
+			subject = output.Marker(markMerge) + " " + subject
+		}
+
+		rows = append(rows, tui.Row{
+			Marker:    output.Marker(markOf(record)),
+			SHA:       record.CommitSHA(),
+			Branches:  strings.Join(record.ReviewBranchNamesForUI(), ","),
+			Subject:   subject,
+			HasReview: record.HasReview(),
+			Skipped:   record.IsSkipped(),
+		})
+	}
+
+	c.model.SetRows(rows)
+	c.preview(cmd)
+
+	return nil
+}
+
+func (c *uiCommand) preview(cmd *cobra.Command) {
+	pos := c.model.Selected()
+	if pos == 0 {
+		c.model.SetPreview(nil)
+
+		return
+	}
+
+	record := &c.records[pos-1]
+
+	lines, err := git.DiffLines(cmd.Context(), record.DiffBase(), record.CommitSHA())
+	if err != nil {
+		lines = []string{err.Error()}
+	}
+
+	c.model.SetPreview(lines)
+}
+
+func (c *uiCommand) open(cmd *cobra.Command, pos int) {
+	branches := c.records[pos-1].ReviewBranchNames()
+
+	url, err := git.MergeRequestURL(cmd.Context(), branches[0])
+	if err != nil {
+		c.model.SetStatus(err.Error())
+
+		return
+	}
+
+	opener := "xdg-open"
+	if runtime.GOOS == "darwin" {
+		opener = "open"
+	}
+
+	browser := exec.CommandContext(cmd.Context(), opener, url)
+	if err := browser.Start(); err != nil {
+		c.model.SetStatus(url)
+
+		return
+	}
+
+	go func() {
+		_ = browser.Wait()
+	}()
+
+	c.model.SetStatus("opened " + url)
+}
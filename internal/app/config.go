@@ -1,42 +1,360 @@
 package app
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 
 	"gopkg.in/yaml.v2"
 )
 
-var Config struct {
+var Config = Configuration{
+	MergeRequestDraft: true,
+	Color:             "auto",
+	Colors: map[string]string{
+		"subject":  "yellow",
+		"branch":   "yellow",
+		"new":      "yellow",
+		"old":      "red",
+		"ok":       "green",
+		"switch":   "yellow",
+		"merge":    "yellow",
+		"skip":     "grey",
+		"landed":   "green",
+		"rebased":  "yellow",
+		"conflict": "red",
+	},
+	Markers: map[string]string{
+		"new":    "++",
+		"old":    "--",
+		"ok":     "ok",
+		"switch": "**",
+		"merge":  "(merge)",
+		"skip":   "skip",
+	},
+}
+
+type Configuration struct {
 	Debug              bool
 	Verbose            bool
 	EnableGitPush      bool
 	UseSubjectToMatch  bool
+	UseChangeID        bool
 	MergeRequestPrefix string
-	Persistent         struct {
-		FeatureBranches []FeatureBranch `yaml:"features"`
+	// MergeRequestDraft новые MR создаются черновиками
+	MergeRequestDraft bool
+	// MergeRequestAPI создавать MR через API GitLab вместо push options
+	MergeRequestAPI bool
+	// MergeRequestAutoReady после rebase --fetch снимать draft с нижнего MR, если MR под ним влиты
+	MergeRequestAutoReady bool
+	// Color режим цвета: auto, always или never
+	Color string
+	// Colors цвета элементов вывода, Markers метки статусов записей в list
+	Colors  map[string]string
+	Markers map[string]string
+	// MergeRequestNotes публиковать в MR заметку с изменениями после обновления review ветки
+	MergeRequestNotes bool
+	// GitLab доступ к API, пустые URL и Project определяются по origin
+	GitLab struct {
+		URL     string
+		Project string
+		Token   string
 	}
+	// Persistent изменяемое состояние репозитория, хранится в .git/giiter
+	Persistent State
+	// Shared общие настройки репозитория из .giiter.yml, их можно закоммитить
+	Shared struct {
+		Filters       Filters              `yaml:"filters,omitempty"`
+		Settings      map[string]string    `yaml:"settings,omitempty"`
+		MergeRequests MergeRequestSettings `yaml:"merge_requests,omitempty"`
+	}
+}
+
+type State struct {
+	FeatureBranches []FeatureBranch `yaml:"features"`
+	// Pending хранится отдельно в каталоге git своей рабочей копии, см. LoadConfig
+	Pending *Operation `yaml:"-"`
+}
+
+// legacyState состояние в старом формате, когда незавершенная операция хранилась вместе с feature ветками
+type legacyState struct {
+	FeatureBranches []FeatureBranch `yaml:"features"`
+	Pending         *Operation      `yaml:"pending,omitempty"`
+}
+
+// Operation незавершенная операция giiter, ее можно продолжить через continue или отменить через abort
+type Operation struct {
+	Kind          string `yaml:"kind"`
+	BaseBranch    string `yaml:"base_branch"`
+	FeatureBranch string `yaml:"feature_branch"`
+	Commit        string `yaml:"commit,omitempty"`
+	Head          string `yaml:"head,omitempty"`
+	ReviewBranch  string `yaml:"review_branch,omitempty"`
+	TargetBranch  string `yaml:"target_branch,omitempty"`
+	// Landed rebase --fetch остановился на конфликте, а часть записей уже влита в base ветку
+	Landed bool `yaml:"landed,omitempty"`
+	// SplitFirst и SplitSecond части коммита split, review ветки для них создаются после rebase,
+	// в том числе после конфликта и giiter continue
+	SplitFirst  string `yaml:"split_first,omitempty"`
+	SplitSecond string `yaml:"split_second,omitempty"`
+}
+
+// Filters описывает коммиты feature ветки, для которых не создаются review ветки
+type Filters struct {
+	SkipFixup bool     `yaml:"skip_fixup,omitempty"`
+	Subjects  []string `yaml:"subjects,omitempty"`
+	Paths     []string `yaml:"paths,omitempty"`
+}
+
+// MergeRequestOptions метаданные новых MR, пользователи задаются именами в GitLab,
+// nil в RemoveSourceBranch и Squash значит, что слой настроек их не задает
+type MergeRequestOptions struct {
+	Labels             []string `yaml:"labels,omitempty"`
+	Assignees          []string `yaml:"assignees,omitempty"`
+	Reviewers          []string `yaml:"reviewers,omitempty"`
+	Milestone          string   `yaml:"milestone,omitempty"`
+	RemoveSourceBranch *bool    `yaml:"remove_source_branch,omitempty"`
+	Squash             *bool    `yaml:"squash,omitempty"`
+}
+
+// Enabled возвращает значение необязательного флага, незаданный флаг выключен
+func Enabled(flag *bool) bool {
+	return flag != nil && *flag
+}
+
+// MergeRequestSettings метаданные MR для всего репозитория и дополнительные для отдельных feature веток
+type MergeRequestSettings struct {
+	MergeRequestOptions `yaml:",inline"`
+	Features            map[string]MergeRequestOptions `yaml:"features,omitempty"`
 }
 
 type FeatureBranch struct {
-	BaseBranch string `yaml:"base_branch"`
-	BranchName string `yaml:"feature_branch"`
+	BaseBranch string            `yaml:"base_branch"`
+	BranchName string            `yaml:"feature_branch"`
+	Changes    map[string]string `yaml:"changes,omitempty"`
+}
+
+// snapshots файлы в том виде, в котором они были загружены,
+// nil защищает файлы от перезаписи пустой конфигурацией, если загрузка не выполнялась,
+// а пустой snapshot заставляет записать файл, например после переноса состояния старого формата
+var snapshots struct {
+	shared  []byte
+	state   []byte
+	pending []byte
+}
+
+// LoadConfig загружает общие настройки из cfgFile, общее для рабочих копий состояние из stateFile
+// и незавершенную операцию рабочей копии из pendingFile. Состояние из старого .giiter.yml
+// переносится в stateFile, тогда migrated будет true, пустой stateFile означает работу вне репозитория
+func LoadConfig(cfgFile, stateFile, pendingFile string) (migrated bool, err error) {
+	if _, err = loadYAML(cfgFile, &Config.Shared); err != nil {
+		return false, err
+	}
+
+	if snapshots.shared, err = yaml.Marshal(Config.Shared); err != nil {
+		return false, err
+	}
+
+	if stateFile == "" {
+		return false, nil
+	}
+
+	var legacy legacyState
+
+	// состояние перечитывается и во время команды, например в ui перед каждым действием
+	Config.Persistent = State{}
+
+	found, err := loadYAML(stateFile, &legacy)
+	if err != nil {
+		return false, err
+	}
+
+	if !found {
+		if migrated, err = migrateState(cfgFile, stateFile, pendingFile, &legacy); err != nil {
+			return false, err
+		}
+	}
+
+	Config.Persistent.FeatureBranches = legacy.FeatureBranches
+
+	if snapshots.state, err = yaml.Marshal(Config.Persistent); err != nil {
+		return false, err
+	}
+
+	if migrated {
+		return true, nil
+	}
+
+	// операция старого формата переходит в ту рабочую копию, которая первой загрузила состояние
+	if legacy.Pending != nil {
+		snapshots.state = []byte{}
+	}
+
+	if _, err = loadYAML(pendingFile, &Config.Persistent.Pending); err != nil {
+		return false, err
+	}
+
+	if Config.Persistent.Pending == nil {
+		Config.Persistent.Pending = legacy.Pending
+	} else if legacy.Pending != nil {
+		return false, fmt.Errorf("%s and %s both have pending operation, remove one of them", stateFile, pendingFile)
+	}
+
+	if snapshots.pending, err = yaml.Marshal(Config.Persistent.Pending); err != nil {
+		return false, err
+	}
+
+	if legacy.Pending != nil {
+		snapshots.pending = []byte{}
+	}
+
+	return migrated, nil
 }
 
-func LoadConfig(cfgFile string) error {
-	f, err := os.Open(cfgFile)
+func loadYAML(file string, out interface{}) (bool, error) {
+	f, err := os.Open(file)
 	if os.IsNotExist(err) {
-		return nil
+		return false, nil
 	}
 
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer f.Close()
 
 	dec := yaml.NewDecoder(f)
-	if err := dec.Decode(&Config.Persistent); err != nil {
+	if err := dec.Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("%s: %w", file, err)
+	}
+
+	return true, nil
+}
+
+// migrateState переносит feature ветки в stateFile и незавершенную операцию в pendingFile из .giiter.yml,
+// сам .giiter.yml может быть закоммичен, поэтому он не удаляется, а переписывается только с общими настройками
+func migrateState(cfgFile, stateFile, pendingFile string, legacy *legacyState) (bool, error) {
+	if _, err := loadYAML(cfgFile, legacy); err != nil {
+		return false, err
+	}
+
+	if len(legacy.FeatureBranches) == 0 && legacy.Pending == nil {
+		return false, nil
+	}
+
+	if err := writeYAML(stateFile, State{FeatureBranches: legacy.FeatureBranches}); err != nil {
+		return false, err
+	}
+
+	Config.Persistent.Pending = legacy.Pending
+
+	if legacy.Pending != nil {
+		if err := writeYAML(pendingFile, legacy.Pending); err != nil {
+			return false, err
+		}
+	}
+
+	pending, err := yaml.Marshal(legacy.Pending)
+	if err != nil {
+		return false, err
+	}
+
+	snapshots.pending = pending
+
+	return true, writeYAML(cfgFile, Config.Shared)
+}
+
+// SaveConfig сохраняет изменившиеся настройки и состояние, в том числе после неуспешной команды,
+// чтобы не потерять незавершенную операцию
+func SaveConfig(cfgFile, stateFile, pendingFile string) error {
+	if snapshots.shared != nil {
+		if err := saveChanged(cfgFile, Config.Shared, snapshots.shared); err != nil {
+			return err
+		}
+	}
+
+	if snapshots.state == nil {
+		return nil
+	}
+
+	if err := saveChanged(stateFile, Config.Persistent, snapshots.state); err != nil {
+		return err
+	}
+
+	if Config.Persistent.Pending != nil {
+		return saveChanged(pendingFile, Config.Persistent.Pending, snapshots.pending)
+	}
+
+	// операция завершена, файл больше не нужен
+	if err := os.Remove(pendingFile); err != nil && !os.IsNotExist(err) {
 		return err
 	}
 
 	return nil
 }
+
+func saveChanged(file string, value interface{}, snapshot []byte) error {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(data, snapshot) {
+		return nil
+	}
+
+	return writeConfig(file, data)
+}
+
+func writeYAML(file string, value interface{}) error {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return writeConfig(file, data)
+}
+
+// InitConfig создает файл общих настроек, если его еще нет
+func InitConfig(cfgFile string) (bool, error) {
+	if _, err := os.Stat(cfgFile); err == nil {
+		return false, nil
+	}
+
+	return true, writeYAML(cfgFile, Config.Shared)
+}
+
+// writeConfig записывает файл атомарно через временный файл в том же каталоге
+func writeConfig(file string, data []byte) error {
+	dir := filepath.Dir(file)
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	//nolint:gosec // конфигурация не секретная
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), file)
+}
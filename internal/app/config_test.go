@@ -0,0 +1,162 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	legacyFeatures = "features:\n- base_branch: master\n  feature_branch: fa\n"
+	legacyPending  = "pending:\n  kind: edit\n  base_branch: master\n  feature_branch: fa\n  commit: \"1111111\"\n"
+	sharedFilters  = "filters:\n  skip_fixup: true\n"
+	pendingEdit    = "kind: edit\nbase_branch: master\nfeature_branch: fa\ncommit: \"1111111\"\n"
+)
+
+// configFiles файлы giiter во временном репозитории, у второй рабочей копии свой pending
+type configFiles struct {
+	cfg, state, pending, otherPending string
+}
+
+func newConfigFiles(t *testing.T) configFiles {
+	withConfig(t)
+
+	savedSnapshots := snapshots
+	Config.Shared.Filters = Filters{}
+	Config.Shared.Settings = nil
+
+	t.Cleanup(func() {
+		snapshots = savedSnapshots
+	})
+
+	dir := t.TempDir()
+
+	return configFiles{
+		cfg:          filepath.Join(dir, ".giiter.yml"),
+		state:        filepath.Join(dir, ".git", "giiter", "state.yml"),
+		pending:      filepath.Join(dir, ".git", "giiter", "pending.yml"),
+		otherPending: filepath.Join(dir, ".git", "worktrees", "wt", "giiter", "pending.yml"),
+	}
+}
+
+func readFile(t *testing.T, file string) string {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return ""
+	}
+
+	require.NoError(t, err)
+
+	return string(data)
+}
+
+func TestLoadConfigMigration(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      string
+		state    string
+		migrated bool
+		features int
+		// файлы после LoadConfig и SaveConfig, пустая строка значит, что файла нет
+		wantCfg     string
+		wantState   string
+		wantPending string
+		err         string
+	}{
+		{
+			name:    "new repository",
+			cfg:     sharedFilters,
+			wantCfg: sharedFilters,
+		},
+		{
+			name:      "features in .giiter.yml",
+			cfg:       sharedFilters + legacyFeatures,
+			migrated:  true,
+			features:  1,
+			wantCfg:   sharedFilters,
+			wantState: legacyFeatures,
+		},
+		{
+			name:        "features and pending in .giiter.yml",
+			cfg:         legacyFeatures + legacyPending,
+			migrated:    true,
+			features:    1,
+			wantCfg:     "{}\n",
+			wantState:   legacyFeatures,
+			wantPending: pendingEdit,
+		},
+		{
+			name:        "pending in state file",
+			cfg:         sharedFilters,
+			state:       legacyFeatures + legacyPending,
+			features:    1,
+			wantCfg:     sharedFilters,
+			wantState:   legacyFeatures,
+			wantPending: pendingEdit,
+		},
+		{
+			name:      "current format",
+			cfg:       sharedFilters + legacyFeatures,
+			state:     legacyFeatures,
+			features:  1,
+			wantCfg:   sharedFilters + legacyFeatures,
+			wantState: legacyFeatures,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := newConfigFiles(t)
+
+			require.NoError(t, os.WriteFile(files.cfg, []byte(tt.cfg), 0o644))
+
+			if tt.state != "" {
+				require.NoError(t, os.MkdirAll(filepath.Dir(files.state), 0o755))
+				require.NoError(t, os.WriteFile(files.state, []byte(tt.state), 0o644))
+			}
+
+			migrated, err := LoadConfig(files.cfg, files.state, files.pending)
+			require.NoError(t, err)
+			require.Equal(t, tt.migrated, migrated)
+			require.Len(t, Config.Persistent.FeatureBranches, tt.features)
+
+			require.NoError(t, SaveConfig(files.cfg, files.state, files.pending))
+
+			require.Equal(t, tt.wantCfg, readFile(t, files.cfg))
+			require.Equal(t, tt.wantPending, readFile(t, files.pending))
+
+			require.Equal(t, tt.wantState, readFile(t, files.state))
+		})
+	}
+}
+
+func TestPendingPerWorktree(t *testing.T) {
+	files := newConfigFiles(t)
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(files.state), 0o755))
+	require.NoError(t, os.WriteFile(files.state, []byte(legacyFeatures), 0o644))
+	require.NoError(t, os.WriteFile(files.pending, []byte(pendingEdit), 0o644))
+
+	_, err := LoadConfig(files.cfg, files.state, files.pending)
+	require.NoError(t, err)
+	require.NotNil(t, Config.Persistent.Pending)
+	require.Equal(t, "1111111", Config.Persistent.Pending.Commit)
+
+	// вторая рабочая копия видит те же feature ветки, но не чужую операцию
+	_, err = LoadConfig(files.cfg, files.state, files.otherPending)
+	require.NoError(t, err)
+	require.Len(t, Config.Persistent.FeatureBranches, 1)
+	require.Nil(t, Config.Persistent.Pending)
+
+	require.NoError(t, SaveConfig(files.cfg, files.state, files.otherPending))
+	require.Equal(t, pendingEdit, readFile(t, files.pending))
+	require.NoFileExists(t, files.otherPending)
+
+	// операция в старом формате и в файле рабочей копии сразу
+	require.NoError(t, os.WriteFile(files.state, []byte(legacyFeatures+legacyPending), 0o644))
+
+	_, err = LoadConfig(files.cfg, files.state, files.pending)
+	require.EqualError(t, err, files.state+" and "+files.pending+" both have pending operation, remove one of them")
+}
@@ -0,0 +1,163 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const lockPollInterval = 100 * time.Millisecond
+
+// Lock рекомендательная блокировка репозитория, файл блокировки содержит PID владельца
+type Lock struct {
+	file string
+	info os.FileInfo
+}
+
+// AcquireLock создает файл блокировки, если его держит другой процесс, то ждет до wait,
+// блокировка завершившегося процесса снимается
+func AcquireLock(file string, wait time.Duration) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(wait)
+
+	for {
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			_, err = fmt.Fprintf(f, "%d\n", os.Getpid())
+			if errClose := f.Close(); err == nil {
+				err = errClose
+			}
+
+			var info os.FileInfo
+			if err == nil {
+				info, err = os.Stat(file)
+			}
+
+			if err != nil {
+				_ = os.Remove(file)
+
+				return nil, err
+			}
+
+			return &Lock{file: file, info: info}, nil
+		}
+
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		pid, checked, alive := lockHolder(file)
+		if !alive {
+			// владелец завершился не сняв блокировку
+			if err := takeOver(file, checked); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("repository is locked by giiter (pid %d), retry later or use --wait", pid)
+		}
+
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// lockHolder возвращает PID владельца блокировки, проверенный файл и признак того, что владелец еще работает,
+// nil файл значит, что блокировки уже нет. Файл проверяется до чтения, поэтому прочитанный PID
+// принадлежит этому файлу или более новому
+func lockHolder(file string) (int, os.FileInfo, bool) {
+	info, err := os.Stat(file)
+	if os.IsNotExist(err) {
+		return 0, nil, false
+	}
+
+	data, errRead := os.ReadFile(file)
+	if os.IsNotExist(errRead) {
+		return 0, nil, false
+	}
+
+	pid, errAtoi := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || errRead != nil || errAtoi != nil {
+		// файл только что создан и PID еще не записан, или испорчен
+		return 0, info, err == nil && time.Since(info.ModTime()) < time.Second
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return pid, info, false
+	}
+
+	err = process.Signal(syscall.Signal(0))
+
+	return pid, info, err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// takeOver снимает блокировку завершившегося владельца. Между проверкой и удалением блокировку мог
+// снять и взять другой giiter, поэтому файл сначала атомарно переименовывается, и удаляется,
+// только если это проверенный файл и его владелец все еще не работает, чужая свежая блокировка
+// возвращается на место. Новый файл может получить inode удаленного, поэтому одного SameFile мало
+func takeOver(file string, checked os.FileInfo) error {
+	if checked == nil {
+		return nil
+	}
+
+	moved := fmt.Sprintf("%s.%d.stale", file, os.Getpid())
+
+	if err := os.Rename(file, moved); err != nil {
+		if os.IsNotExist(err) {
+			// блокировку уже снял другой процесс
+			return nil
+		}
+
+		return err
+	}
+
+	defer os.Remove(moved)
+
+	info, err := os.Stat(moved)
+	if err != nil {
+		return err
+	}
+
+	if _, _, alive := lockHolder(moved); os.SameFile(info, checked) && !alive {
+		return nil
+	}
+
+	if err := os.Link(moved, file); err != nil {
+		return fmt.Errorf("lock %s changed while removing stale lock, retry: %w", file, err)
+	}
+
+	return nil
+}
+
+// Release снимает блокировку, если файл блокировки все еще наш
+func (l *Lock) Release() error {
+	if l == nil {
+		return nil
+	}
+
+	info, err := os.Stat(l.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !os.SameFile(info, l.info) {
+		return fmt.Errorf("lock %s was taken over by another giiter", l.file)
+	}
+
+	return os.Remove(l.file)
+}
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// deadPID возвращает PID уже завершившегося процесса
+func deadPID(t *testing.T) int {
+	cmd := exec.Command("true")
+	require.NoError(t, cmd.Run())
+
+	return cmd.Process.Pid
+}
+
+func writeLock(t *testing.T, file string, pid int) {
+	require.NoError(t, os.WriteFile(file, []byte(strconv.Itoa(pid)+"\n"), 0o644))
+}
+
+// replaceLock подменяет файл блокировки новым файлом, у которого точно другой inode
+func replaceLock(t *testing.T, file string, pid int) {
+	writeLock(t, file+".new", pid)
+	require.NoError(t, os.Rename(file+".new", file))
+}
+
+func TestAcquireLock(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "giiter", "lock")
+
+	lock, err := AcquireLock(file, 0)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("%d\n", os.Getpid()), string(data))
+
+	// владелец жив, второй giiter не ждет
+	_, err = AcquireLock(file, 0)
+	require.EqualError(t, err, fmt.Sprintf("repository is locked by giiter (pid %d), retry later or use --wait", os.Getpid()))
+
+	require.NoError(t, lock.Release())
+	require.NoFileExists(t, file)
+	require.NoError(t, lock.Release())
+}
+
+func TestAcquireStaleLock(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "lock")
+	writeLock(t, file, deadPID(t))
+
+	lock, err := AcquireLock(file, 0)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("%d\n", os.Getpid()), string(data))
+
+	require.NoError(t, lock.Release())
+}
+
+func TestReleaseTakenOverLock(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "lock")
+
+	lock, err := AcquireLock(file, 0)
+	require.NoError(t, err)
+
+	// другой giiter посчитал блокировку брошенной и взял свою
+	replaceLock(t, file, os.Getpid())
+
+	require.EqualError(t, lock.Release(), "lock "+file+" was taken over by another giiter")
+	require.FileExists(t, file)
+}
+
+func TestTakeOverFreshLock(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "lock")
+	writeLock(t, file, deadPID(t))
+
+	_, checked, alive := lockHolder(file)
+	require.False(t, alive)
+
+	// пока проверяли, брошенную блокировку снял и взял другой giiter,
+	// его файл может получить inode удаленного
+	require.NoError(t, os.Remove(file))
+	writeLock(t, file, os.Getpid())
+
+	require.NoError(t, takeOver(file, checked))
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("%d\n", os.Getpid()), string(data))
+}
+
+func TestConcurrentTakeOver(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "lock")
+		writeLock(t, file, deadPID(t))
+
+		var (
+			wg   sync.WaitGroup
+			errs = make([]error, 2)
+		)
+
+		for j := range errs {
+			wg.Add(1)
+
+			go func(j int) {
+				defer wg.Done()
+
+				_, errs[j] = AcquireLock(file, 0)
+			}(j)
+		}
+
+		wg.Wait()
+
+		// блокировку получает ровно один giiter
+		if errs[0] == nil {
+			require.Error(t, errs[1])
+		} else {
+			require.NoError(t, errs[1])
+		}
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+	}
+}
@@ -0,0 +1,302 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Origin источник значения настройки, слои перечислены по возрастанию приоритета
+const (
+	OriginDefault = "default"
+	OriginUser    = "user"
+	OriginRepo    = "repo"
+	OriginEnv     = "env"
+	OriginFlag    = "flag"
+)
+
+const envPrefix = "GIITER_"
+
+// Setting настройка, которую можно задать в файлах конфигурации, переменной окружения и флагом
+type Setting struct {
+	Key  string
+	Flag string
+	ptr  interface{}
+	// secret значение не показывается в giiter config list и get и не пишется в общий файл репозитория
+	secret bool
+}
+
+// mapValue значение настройки, которое хранится в словаре
+type mapValue struct {
+	values map[string]string
+	key    string
+}
+
+// Env имя переменной окружения для настройки
+func (s Setting) Env() string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(s.Key, ".", "_"))
+}
+
+func (s Setting) set(value string) error {
+	switch ptr := s.ptr.(type) {
+	case *bool:
+		b, err := s.normalize(value)
+		if err != nil {
+			return err
+		}
+
+		*ptr = b == "true"
+	case *string:
+		*ptr = value
+	case mapValue:
+		ptr.values[ptr.key] = value
+	}
+
+	return nil
+}
+
+// normalize проверяет значение и приводит его к каноническому виду
+func (s Setting) normalize(value string) (string, error) {
+	if _, ok := s.ptr.(*bool); !ok {
+		return value, nil
+	}
+
+	switch strings.ToLower(value) {
+	case "yes", "on":
+		return "true", nil
+	case "no", "off":
+		return "false", nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return "", fmt.Errorf("%s: %q is not a boolean", s.Key, value)
+	}
+
+	return strconv.FormatBool(b), nil
+}
+
+// Value текущее значение настройки
+func (s Setting) Value() string {
+	switch ptr := s.ptr.(type) {
+	case *bool:
+		return strconv.FormatBool(*ptr)
+	case *string:
+		return *ptr
+	case mapValue:
+		return ptr.values[ptr.key]
+	}
+
+	return ""
+}
+
+// Display возвращает значение для списка настроек, секреты скрыты
+func (s Setting) Display() string {
+	if s.secret && s.Value() != "" {
+		return "***"
+	}
+
+	return s.Value()
+}
+
+// Settings все настройки giiter, цвета и метки задаются ключами color.<элемент> и marker.<статус>
+func Settings() []Setting {
+	settings := []Setting{
+		{Key: "debug", Flag: "debug", ptr: &Config.Debug},
+		{Key: "verbose", Flag: "verbose", ptr: &Config.Verbose},
+		{Key: "push", Flag: "push", ptr: &Config.EnableGitPush},
+		{Key: "subj", Flag: "subj", ptr: &Config.UseSubjectToMatch},
+		{Key: "change_id", Flag: "change-id", ptr: &Config.UseChangeID},
+		{Key: "mr_prefix", Flag: "prefix", ptr: &Config.MergeRequestPrefix},
+		{Key: "mr_draft", Flag: "draft", ptr: &Config.MergeRequestDraft},
+		{Key: "mr_auto_ready", ptr: &Config.MergeRequestAutoReady},
+		{Key: "mr_api", ptr: &Config.MergeRequestAPI},
+		{Key: "color", Flag: "color", ptr: &Config.Color},
+		{Key: "mr_notes", ptr: &Config.MergeRequestNotes},
+		{Key: "gitlab.url", ptr: &Config.GitLab.URL},
+		{Key: "gitlab.project", ptr: &Config.GitLab.Project},
+		{Key: "gitlab.token", ptr: &Config.GitLab.Token, secret: true},
+	}
+
+	settings = append(settings, mapSettings("color.", Config.Colors)...)
+	settings = append(settings, mapSettings("marker.", Config.Markers)...)
+
+	return settings
+}
+
+func mapSettings(prefix string, values map[string]string) []Setting {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	settings := make([]Setting, 0, len(keys))
+	for _, key := range keys {
+		settings = append(settings, Setting{Key: prefix + key, ptr: mapValue{values: values, key: key}})
+	}
+
+	return settings
+}
+
+// FindSetting ищет настройку по ключу
+func FindSetting(key string) (Setting, error) {
+	for _, setting := range Settings() {
+		if setting.Key == key {
+			return setting, nil
+		}
+	}
+
+	return Setting{}, fmt.Errorf("unknown setting %s", key)
+}
+
+// origins источник текущего значения каждой настройки
+var origins = make(map[string]string)
+
+// Origin источник текущего значения настройки
+func Origin(key string) string {
+	if origin, ok := origins[key]; ok {
+		return origin
+	}
+
+	return OriginDefault
+}
+
+// UserConfigFile путь к пользовательскому файлу конфигурации
+func UserConfigFile() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "giiter", "config.yml"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".config", "giiter", "config.yml"), nil
+}
+
+func loadUserSettings(userFile string) (map[string]string, error) {
+	data, err := os.ReadFile(userFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	settings := make(map[string]string)
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("%s: %w", userFile, err)
+	}
+
+	return settings, nil
+}
+
+// ApplySettings применяет слои конфигурации: пользовательский файл, файл репозитория
+// и переменные окружения, значения флагов, заданных в командной строке, не меняются
+func ApplySettings(flagChanged func(flag string) bool) error {
+	userFile, err := UserConfigFile()
+	if err != nil {
+		return err
+	}
+
+	user, err := loadUserSettings(userFile)
+	if err != nil {
+		return err
+	}
+
+	for _, setting := range Settings() {
+		if flagChanged(setting.Flag) {
+			origins[setting.Key] = OriginFlag
+
+			continue
+		}
+
+		layers := []struct {
+			origin string
+			values map[string]string
+		}{
+			{OriginUser, user},
+			{OriginRepo, Config.Shared.Settings},
+		}
+
+		for _, layer := range layers {
+			if value, ok := layer.values[setting.Key]; ok {
+				if err := setting.set(value); err != nil {
+					return fmt.Errorf("%s config: %w", layer.origin, err)
+				}
+
+				origins[setting.Key] = layer.origin
+			}
+		}
+
+		if value, ok := os.LookupEnv(setting.Env()); ok {
+			if err := setting.set(value); err != nil {
+				return fmt.Errorf("%s: %w", setting.Env(), err)
+			}
+
+			origins[setting.Key] = OriginEnv
+		}
+	}
+
+	return nil
+}
+
+// SetSetting записывает настройку в файл репозитория или в пользовательский файл
+func SetSetting(key, value string, user bool) error {
+	setting, err := FindSetting(key)
+	if err != nil {
+		return err
+	}
+
+	value, err = setting.normalize(value)
+	if err != nil {
+		return err
+	}
+
+	// .giiter.yml общий и попадает в репозиторий
+	if setting.secret && !user {
+		return fmt.Errorf("%s is secret, write it to user config with --user or set %s", key, setting.Env())
+	}
+
+	if !user {
+		if Config.Shared.Settings == nil {
+			Config.Shared.Settings = make(map[string]string)
+		}
+
+		Config.Shared.Settings[key] = value
+
+		return nil
+	}
+
+	userFile, err := UserConfigFile()
+	if err != nil {
+		return err
+	}
+
+	settings, err := loadUserSettings(userFile)
+	if err != nil {
+		return err
+	}
+
+	if settings == nil {
+		settings = make(map[string]string)
+	}
+
+	settings[key] = value
+
+	data, err := yaml.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	return writeConfig(userFile, data)
+}
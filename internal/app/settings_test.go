@@ -0,0 +1,147 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// withConfig восстанавливает настройки giiter после теста, пользовательский файл лежит во временном каталоге
+func withConfig(t *testing.T) string {
+	savedConfig, savedOrigins := Config, origins
+	origins = make(map[string]string)
+
+	t.Cleanup(func() {
+		Config, origins = savedConfig, savedOrigins
+	})
+
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+
+	return filepath.Join(dir, "giiter", "config.yml")
+}
+
+func TestSetSettingSecret(t *testing.T) {
+	userFile := withConfig(t)
+
+	err := SetSetting("gitlab.token", "glpat-1", false)
+	require.EqualError(t, err, "gitlab.token is secret, write it to user config with --user or set GIITER_GITLAB_TOKEN")
+	require.Empty(t, Config.Shared.Settings)
+
+	require.NoError(t, SetSetting("gitlab.token", "glpat-1", true))
+
+	data, err := os.ReadFile(userFile)
+	require.NoError(t, err)
+	require.Equal(t, "gitlab.token: glpat-1\n", string(data))
+
+	Config.GitLab.Token = "glpat-1"
+
+	setting, err := FindSetting("gitlab.token")
+	require.NoError(t, err)
+	require.Equal(t, "***", setting.Display())
+	require.Equal(t, "glpat-1", setting.Value())
+}
+
+func TestApplySettings(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   string
+		repo   map[string]string
+		env    string
+		flag   bool
+		value  string
+		origin string
+		err    string
+	}{
+		{name: "default", value: "false", origin: OriginDefault},
+		{name: "user file", user: "subj: \"yes\"\n", value: "true", origin: OriginUser},
+		{name: "repo over user", user: "subj: \"true\"\n", repo: map[string]string{"subj": "false"}, value: "false", origin: OriginRepo},
+		{name: "env over repo", repo: map[string]string{"subj": "false"}, env: "on", value: "true", origin: OriginEnv},
+		{name: "flag over env", env: "true", flag: true, value: "false", origin: OriginFlag},
+		{name: "bad repo value", repo: map[string]string{"subj": "maybe"}, err: `repo config: subj: "maybe" is not a boolean`},
+		{name: "bad env value", env: "maybe", err: `GIITER_SUBJ: subj: "maybe" is not a boolean`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userFile := withConfig(t)
+			Config.Shared.Settings = tt.repo
+
+			if tt.user != "" {
+				require.NoError(t, os.MkdirAll(filepath.Dir(userFile), 0o755))
+				require.NoError(t, os.WriteFile(userFile, []byte(tt.user), 0o644))
+			}
+
+			if tt.env != "" {
+				t.Setenv("GIITER_SUBJ", tt.env)
+			}
+
+			err := ApplySettings(func(flag string) bool { return tt.flag && flag == "subj" })
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			setting, err := FindSetting("subj")
+			require.NoError(t, err)
+			require.Equal(t, tt.value, setting.Value())
+			require.Equal(t, tt.origin, Origin("subj"))
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+		want  string
+		err   string
+	}{
+		{key: "push", value: "yes", want: "true"},
+		{key: "push", value: "ON", want: "true"},
+		{key: "push", value: "1", want: "true"},
+		{key: "push", value: "off", want: "false"},
+		{key: "push", value: "F", want: "false"},
+		{key: "push", value: "maybe", err: `push: "maybe" is not a boolean`},
+		{key: "mr_prefix", value: "Yes", want: "Yes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			setting, err := FindSetting(tt.key)
+			require.NoError(t, err)
+
+			value, err := setting.normalize(tt.value)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, value)
+		})
+	}
+}
+
+func TestSetSetting(t *testing.T) {
+	userFile := withConfig(t)
+
+	require.NoError(t, SetSetting("push", "yes", false))
+	require.Equal(t, map[string]string{"push": "true"}, Config.Shared.Settings)
+
+	require.NoError(t, SetSetting("mr_prefix", "Draft", true))
+	require.NoError(t, SetSetting("color.ok", "blue", true))
+
+	data, err := os.ReadFile(userFile)
+	require.NoError(t, err)
+	require.Equal(t, "color.ok: blue\nmr_prefix: Draft\n", string(data))
+
+	require.EqualError(t, SetSetting("push", "maybe", false), `push: "maybe" is not a boolean`)
+	require.EqualError(t, SetSetting("nope", "1", false), "unknown setting nope")
+}
@@ -0,0 +1,441 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/waffleboot/giiter/internal/app"
+)
+
+// hunk изменение из индекса без контекста, target это индекс коммита feature ветки или -1
+type hunk struct {
+	file     string
+	lines    []string
+	oldStart int
+	oldCount int
+	added    int
+	target   int
+}
+
+// AbsorbReport показывает куда попали изменения из индекса
+type AbsorbReport struct {
+	Absorbed []AbsorbedCommit
+	Left     int
+}
+
+type AbsorbedCommit struct {
+	Commit string
+	Hunks  int
+}
+
+// Absorb раскладывает изменения из индекса по коммитам feature ветки, которые последними меняли эти строки,
+// создает fixup коммиты и встраивает их через autosquash. Изменения для которых коммит не найден остаются в индексе
+func Absorb(ctx context.Context, baseBranch, featureBranch string) (*AbsorbReport, error) {
+	if err := checkNoPendingOperation(); err != nil {
+		return nil, err
+	}
+
+	currentBranch, err := getCurrentBranch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if currentBranch != featureBranch {
+		return nil, fmt.Errorf("checkout %s to absorb staged changes", featureBranch)
+	}
+
+	// blame и apply понимают пути относительно текущего каталога
+	prefix, err := run(ctx, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(prefix) > 0 && prefix[0] != "" {
+		return nil, errors.New("run absorb from the top of the working tree")
+	}
+
+	records, err := State(ctx, baseBranch, featureBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := stackTodo(records)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []string
+
+	for i := range records {
+		if !records[i].IsOldCommit() && !records[i].IsSkipped() {
+			commits = append(commits, records[i].Commits()...)
+		}
+	}
+
+	patch, err := run(ctx, "diff", "--cached", "--unified=0", "--no-color", "--no-ext-diff", "--no-renames")
+	if err != nil {
+		return nil, err
+	}
+
+	hunks := parseHunks(patch)
+	if len(hunks) == 0 {
+		return nil, errors.New("nothing staged to absorb")
+	}
+
+	for i := range hunks {
+		if hunks[i].target, err = blameHunk(ctx, commits, &hunks[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	staged, err := run(ctx, "write-tree")
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := revParse(ctx, featureBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := commitFixups(ctx, commits, hunks)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(report.Absorbed) == 0 {
+		_, err = run(ctx, "read-tree", staged[0])
+
+		return report, err
+	}
+
+	output, err := run(ctx, "merge-base", baseBranch, featureBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	op := &app.Operation{
+		BaseBranch:    baseBranch,
+		FeatureBranch: featureBranch,
+		Head:          head,
+	}
+
+	if err := runRebase(ctx, op,
+		"-c", "sequence.editor=:", "rebase", "-i", "--autosquash", "--autostash", output[0], featureBranch,
+	); err != nil {
+		return report, err
+	}
+
+	// в индекс возвращаются изменения, которые не удалось разложить по коммитам
+	if report.Left > 0 {
+		if _, err := run(ctx, "read-tree", staged[0]); err != nil {
+			return report, err
+		}
+	}
+
+	// fixup коммиты встроились, количество коммитов не поменялось
+	tips, err := newRecordTips(ctx, baseBranch, featureBranch, records, entries)
+	if err != nil {
+		return report, err
+	}
+
+	if err := followRecords(ctx, baseBranch, records, entries, tips); err != nil {
+		return report, err
+	}
+
+	return report, restackChildren(ctx, featureBranch, head)
+}
+
+// commitFixups создает fixup коммит для каждого коммита feature ветки, в который попали изменения
+func commitFixups(ctx context.Context, commits []string, hunks []hunk) (*AbsorbReport, error) {
+	report := new(AbsorbReport)
+
+	if _, err := run(ctx, "reset", "-q"); err != nil {
+		return nil, err
+	}
+
+	// committed отмечает изменения, которые уже попали в fixup коммиты
+	committed := make([]bool, len(hunks))
+
+	for target := range commits {
+		var batch []int
+
+		for i := range hunks {
+			if hunks[i].target == target {
+				batch = append(batch, i)
+			}
+		}
+
+		if len(batch) == 0 {
+			continue
+		}
+
+		if err := applyHunks(ctx, hunks, committed, batch); err != nil {
+			return nil, err
+		}
+
+		if _, err := run(ctx, "commit", "-q", "--no-verify", "--fixup="+commits[target]); err != nil {
+			return nil, err
+		}
+
+		for _, i := range batch {
+			committed[i] = true
+		}
+
+		report.Absorbed = append(report.Absorbed, AbsorbedCommit{
+			Commit: commits[target],
+			Hunks:  len(batch),
+		})
+	}
+
+	for i := range hunks {
+		if hunks[i].target < 0 {
+			report.Left++
+		}
+	}
+
+	return report, nil
+}
+
+// applyHunks добавляет в индекс изменения batch
+func applyHunks(ctx context.Context, hunks []hunk, committed []bool, batch []int) error {
+	f, err := os.CreateTemp("", "giiter-absorb-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := f.WriteString(hunksPatch(hunks, committed, batch)); err != nil {
+		return err
+	}
+
+	_, err = run(ctx, "apply", "--cached", "--unidiff-zero", f.Name())
+
+	return err
+}
+
+// hunksPatch собирает патч из изменений batch для индекса, в котором уже есть committed изменения,
+// номера строк пересчитываются с учетом закоммиченных изменений и изменений batch выше по файлу
+func hunksPatch(hunks []hunk, committed []bool, batch []int) string {
+	var patch strings.Builder
+
+	inBatch := make(map[int]bool, len(batch))
+	for _, i := range batch {
+		inBatch[i] = true
+	}
+
+	file := ""
+
+	for _, i := range batch {
+		h := hunks[i]
+
+		if h.file != file {
+			file = h.file
+			fmt.Fprintf(&patch, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n", file, file, file, file)
+		}
+
+		oldShift, newShift := 0, 0
+
+		for j := range hunks {
+			if hunks[j].file != h.file || hunks[j].oldStart >= h.oldStart {
+				continue
+			}
+
+			delta := hunks[j].added - hunks[j].oldCount
+
+			if committed[j] {
+				oldShift += delta
+			}
+
+			if inBatch[j] {
+				newShift += delta
+			}
+		}
+
+		oldStart := h.oldStart + oldShift
+		newStart := oldStart + newShift
+
+		switch {
+		case h.oldCount == 0:
+			newStart++
+		case h.added == 0:
+			newStart--
+		}
+
+		fmt.Fprintf(&patch, "@@ -%d,%d +%d,%d @@\n", oldStart, h.oldCount, newStart, h.added)
+
+		for _, line := range h.lines {
+			patch.WriteString(line + "\n")
+		}
+	}
+
+	return patch.String()
+}
+
+// parseHunks разбирает diff без контекста, новые, удаленные и бинарные файлы не раскладываются
+func parseHunks(patch []string) []hunk {
+	var (
+		hunks  []hunk
+		file   string
+		header bool
+		skip   bool
+	)
+
+	for _, line := range patch {
+		if strings.HasPrefix(line, "diff --git ") {
+			file, header, skip = "", true, false
+
+			continue
+		}
+
+		if header && !strings.HasPrefix(line, "@@ ") {
+			switch {
+			case strings.HasPrefix(line, "+++ b/"):
+				file = line[len("+++ b/"):]
+			case strings.HasPrefix(line, "--- a/"), strings.HasPrefix(line, "index "):
+			default:
+				// новые, удаленные, бинарные файлы, пути в кавычках
+				skip = true
+			}
+
+			continue
+		}
+
+		header = false
+
+		if skip || file == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "@@ "):
+			h, ok := parseHunkHeader(line)
+			if !ok {
+				skip = true
+
+				continue
+			}
+
+			h.file = file
+			hunks = append(hunks, h)
+		case len(hunks) > 0 && (strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-")),
+			// без "\ No newline at end of file" apply добавит перевод строки в конец файла
+			len(hunks) > 0 && strings.HasPrefix(line, "\\"):
+			hunks[len(hunks)-1].lines = append(hunks[len(hunks)-1].lines, line)
+		}
+	}
+
+	return hunks
+}
+
+// parseHunkHeader разбирает строку вида @@ -a,b +c,d @@
+func parseHunkHeader(line string) (hunk, bool) {
+	fs := strings.Fields(line)
+	if len(fs) < 3 {
+		return hunk{}, false
+	}
+
+	oldStart, oldCount, ok := parseRange(fs[1])
+	if !ok {
+		return hunk{}, false
+	}
+
+	_, added, ok := parseRange(fs[2])
+	if !ok {
+		return hunk{}, false
+	}
+
+	return hunk{
+		oldStart: oldStart,
+		oldCount: oldCount,
+		added:    added,
+		target:   -1,
+	}, true
+}
+
+// parseRange разбирает -a,b или +c,d, без ,b в диапазоне одна строка
+func parseRange(s string) (start, count int, ok bool) {
+	if len(s) < 2 || (s[0] != '-' && s[0] != '+') {
+		return 0, 0, false
+	}
+
+	s = s[1:]
+	count = 1
+
+	if comma := strings.Index(s, ","); comma >= 0 {
+		var err error
+
+		if count, err = strconv.Atoi(s[comma+1:]); err != nil {
+			return 0, 0, false
+		}
+
+		s = s[:comma]
+	}
+
+	start, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return start, count, true
+}
+
+// blameHunk возвращает индекс последнего коммита feature ветки, который менял строки изменения,
+// для вставки берется строка перед ней
+func blameHunk(ctx context.Context, commits []string, h *hunk) (int, error) {
+	from, to := h.oldStart, h.oldStart+h.oldCount-1
+	if h.oldCount == 0 {
+		to = from
+	}
+
+	if from < 1 {
+		from, to = 1, 1
+	}
+
+	output, err := run(ctx, "blame", "--porcelain", "-L", fmt.Sprintf("%d,%d", from, to), "HEAD", "--", h.file)
+	if err != nil {
+		// например вставка в пустой файл
+		return -1, nil //nolint:nilerr // изменение остается в индексе
+	}
+
+	target := -1
+
+	for _, line := range output {
+		fs := strings.Fields(line)
+		if len(fs) < 3 || !isFullSHA(fs[0]) {
+			continue
+		}
+
+		for i := len(commits) - 1; i > target; i-- {
+			if strings.HasPrefix(fs[0], commits[i]) {
+				target = i
+
+				break
+			}
+		}
+	}
+
+	return target, nil
+}
+
+// isFullSHA отличает заголовок строки blame --porcelain от остальных строк,
+// SHA-1 занимает 40 символов, SHA-256 в репозиториях с objectFormat=sha256 занимает 64
+func isFullSHA(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,212 @@
+package git
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		in    string
+		start int
+		count int
+		ok    bool
+	}{
+		{in: "-12,3", start: 12, count: 3, ok: true},
+		{in: "+7", start: 7, count: 1, ok: true},
+		{in: "-4,0", start: 4, count: 0, ok: true},
+		{in: "+0,0", start: 0, count: 0, ok: true},
+		{in: "-x,1"},
+		{in: "-1,y"},
+		{in: "12,3"},
+		{in: "-"},
+		{in: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			start, count, ok := parseRange(tt.in)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.start, start)
+			require.Equal(t, tt.count, count)
+		})
+	}
+}
+
+func TestParseHunkHeader(t *testing.T) {
+	tests := []struct {
+		line string
+		want hunk
+		ok   bool
+	}{
+		{line: "@@ -2 +2 @@", want: hunk{oldStart: 2, oldCount: 1, added: 1, target: -1}, ok: true},
+		{line: "@@ -4,0 +5,2 @@ func main() {", want: hunk{oldStart: 4, oldCount: 0, added: 2, target: -1}, ok: true},
+		{line: "@@ -7,2 +8,0 @@", want: hunk{oldStart: 7, oldCount: 2, added: 0, target: -1}, ok: true},
+		{line: "@@ -0,0 +1 @@", want: hunk{oldStart: 0, oldCount: 0, added: 1, target: -1}, ok: true},
+		{line: "@@ -1"},
+		{line: "@@ -a +1 @@"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			h, ok := parseHunkHeader(tt.line)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.want, h)
+		})
+	}
+}
+
+// stagedPatch diff --cached --unified=0 с изменениями в начале, середине и конце файлов
+var stagedPatch = []string{
+	"diff --git a/a.go b/a.go",
+	"index 1111111..2222222 100644",
+	"--- a/a.go",
+	"+++ b/a.go",
+	"@@ -2 +2 @@ package a",
+	"-b",
+	"+B",
+	"@@ -4,0 +5,2 @@ func a() {",
+	"+x",
+	"+y",
+	"@@ -7,2 +8,0 @@",
+	"-g",
+	"-h",
+	"diff --git a/new.go b/new.go",
+	"new file mode 100644",
+	"index 0000000..3333333",
+	"--- /dev/null",
+	"+++ b/new.go",
+	"@@ -0,0 +1 @@",
+	"+package a",
+	"diff --git a/b.go b/b.go",
+	"index 4444444..5555555 100644",
+	"--- a/b.go",
+	"+++ b/b.go",
+	"@@ -0,0 +1 @@",
+	"+// Package b",
+	"@@ -3 +4 @@",
+	"-c",
+	`\ No newline at end of file`,
+	"+C",
+	`\ No newline at end of file`,
+	"diff --git a/img.png b/img.png",
+	"index 6666666..7777777 100644",
+	"Binary files a/img.png and b/img.png differ",
+}
+
+func TestParseHunks(t *testing.T) {
+	require.Equal(t, []hunk{
+		{file: "a.go", lines: []string{"-b", "+B"}, oldStart: 2, oldCount: 1, added: 1, target: -1},
+		{file: "a.go", lines: []string{"+x", "+y"}, oldStart: 4, oldCount: 0, added: 2, target: -1},
+		{file: "a.go", lines: []string{"-g", "-h"}, oldStart: 7, oldCount: 2, added: 0, target: -1},
+		{file: "b.go", lines: []string{"+// Package b"}, oldStart: 0, oldCount: 0, added: 1, target: -1},
+		{
+			file:     "b.go",
+			lines:    []string{"-c", `\ No newline at end of file`, "+C", `\ No newline at end of file`},
+			oldStart: 3, oldCount: 1, added: 1, target: -1,
+		},
+	}, parseHunks(stagedPatch))
+}
+
+func TestHunksPatch(t *testing.T) {
+	hunks := parseHunks(stagedPatch)
+
+	tests := []struct {
+		name      string
+		committed []int
+		batch     []int
+		want      []string
+	}{
+		{
+			name:  "add and delete",
+			batch: []int{1, 2},
+			want: []string{
+				"diff --git a/a.go b/a.go", "--- a/a.go", "+++ b/a.go",
+				"@@ -4,0 +5,2 @@", "+x", "+y",
+				// две добавленные строки выше сдвигают удаление в новом файле
+				"@@ -7,2 +8,0 @@", "-g", "-h",
+			},
+		},
+		{
+			name:      "after committed add",
+			committed: []int{0, 1},
+			batch:     []int{2},
+			want: []string{
+				"diff --git a/a.go b/a.go", "--- a/a.go", "+++ b/a.go",
+				"@@ -9,2 +8,0 @@", "-g", "-h",
+			},
+		},
+		{
+			name:      "committed delete below",
+			committed: []int{2},
+			batch:     []int{0},
+			want: []string{
+				"diff --git a/a.go b/a.go", "--- a/a.go", "+++ b/a.go",
+				"@@ -2,1 +2,1 @@", "-b", "+B",
+			},
+		},
+		{
+			name:  "insert at top and no newline",
+			batch: []int{3, 4},
+			want: []string{
+				"diff --git a/b.go b/b.go", "--- a/b.go", "+++ b/b.go",
+				"@@ -0,0 +1,1 @@", "+// Package b",
+				"@@ -3,1 +4,1 @@", "-c", `\ No newline at end of file`, "+C", `\ No newline at end of file`,
+			},
+		},
+		{
+			name:      "no newline after committed insert",
+			committed: []int{3},
+			batch:     []int{4},
+			want: []string{
+				"diff --git a/b.go b/b.go", "--- a/b.go", "+++ b/b.go",
+				"@@ -4,1 +4,1 @@", "-c", `\ No newline at end of file`, "+C", `\ No newline at end of file`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			committed := make([]bool, len(hunks))
+			for _, i := range tt.committed {
+				committed[i] = true
+			}
+
+			require.Equal(t, strings.Join(tt.want, "\n")+"\n", hunksPatch(hunks, committed, tt.batch))
+		})
+	}
+}
+
+func TestBlameHunk(t *testing.T) {
+	sha1 := strings.Repeat("2", 40)
+	sha256 := strings.Repeat("3", 64)
+
+	f := newFakeRepo(t)
+	f.on("blame --porcelain -L 4,5 HEAD -- a.go",
+		sha1+" 4 4 1",
+		"author Alice",
+		"\tline four",
+		sha256+" 5 5 1",
+		"author Bob",
+		"\tline five",
+	)
+	f.on("blame --porcelain -L 1,1 HEAD -- b.go",
+		strings.Repeat("9", 40)+" 1 1 1",
+		"\tpackage b",
+	)
+
+	commits := []string{"1111111", "2222222", "3333333"}
+
+	// последний из коммитов, которые меняли строки
+	target, err := blameHunk(context.Background(), commits, &hunk{file: "a.go", oldStart: 4, oldCount: 2})
+	require.NoError(t, err)
+	require.Equal(t, 2, target)
+
+	// вставка в начало файла, строку меняли только коммиты базы
+	target, err = blameHunk(context.Background(), commits, &hunk{file: "b.go", oldStart: 0, oldCount: 0})
+	require.NoError(t, err)
+	require.Equal(t, -1, target)
+}
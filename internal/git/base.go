@@ -4,8 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
-
-	"github.com/waffleboot/giiter/internal/app"
+	"path/filepath"
 )
 
 func getCurrentBranch(ctx context.Context) (string, error) {
@@ -14,9 +13,61 @@ func getCurrentBranch(ctx context.Context) (string, error) {
 		return "", err
 	}
 
+	if len(output) == 0 {
+		return "", errors.New("HEAD is detached, feature branch is required")
+	}
+
 	return output[0], nil
 }
 
+// TopLevel возвращает корень рабочей копии
+func TopLevel(ctx context.Context) (string, error) {
+	output, err := run(ctx, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+
+	if len(output) == 0 {
+		return "", errors.New("not a git working tree")
+	}
+
+	return output[0], nil
+}
+
+// StateFile возвращает файл состояния giiter, он общий для всех рабочих копий репозитория
+func StateFile(ctx context.Context) (string, error) {
+	commonDir, err := gitDir(ctx, "--git-common-dir")
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(commonDir, "giiter", "state.yml"), nil
+}
+
+// PendingFile возвращает файл незавершенной операции, он свой у каждой рабочей копии,
+// потому что rebase и checkout операции идут в ее HEAD и индексе
+func PendingFile(ctx context.Context) (string, error) {
+	dir, err := gitDir(ctx, "--git-dir")
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "giiter", "pending.yml"), nil
+}
+
+func gitDir(ctx context.Context, option string) (string, error) {
+	output, err := run(ctx, "rev-parse", option)
+	if err != nil {
+		return "", err
+	}
+
+	if len(output) == 0 {
+		return "", errors.New("not a git repository")
+	}
+
+	return filepath.Abs(output[0])
+}
+
 func isProtectedBranch(branchName string) bool {
 	return branchName == "main" || branchName == "master"
 }
@@ -42,28 +93,28 @@ func FindFeatureBranch(ctx context.Context, featureBranch string) (string, error
 	return currentBranch, nil
 }
 
-func findBaseBranch(baseBranch, featureBranch string) string {
-	for i := range app.Config.Persistent.FeatureBranches {
-		item := app.Config.Persistent.FeatureBranches[i]
-		if item.BranchName == featureBranch {
-			if baseBranch != "" {
-				app.Config.Persistent.FeatureBranches[i].BaseBranch = baseBranch
+// findBaseBranch возвращает base ветку feature ветки, -b регистрирует feature ветку или меняет ее base ветку
+// с теми же проверками, что и giiter feature add
+func findBaseBranch(ctx context.Context, baseBranch, featureBranch string) (string, error) {
+	feature := findFeatureConfig(featureBranch)
 
-				return baseBranch
-			}
-
-			return item.BaseBranch
+	if baseBranch == "" {
+		if feature == nil {
+			return "", nil
 		}
+
+		return feature.BaseBranch, nil
+	}
+
+	if feature != nil && feature.BaseBranch == baseBranch {
+		return baseBranch, nil
 	}
 
-	if baseBranch != "" {
-		app.Config.Persistent.FeatureBranches = append(app.Config.Persistent.FeatureBranches, app.FeatureBranch{
-			BaseBranch: baseBranch,
-			BranchName: featureBranch,
-		})
+	if err := AddFeature(ctx, baseBranch, featureBranch); err != nil {
+		return "", err
 	}
 
-	return baseBranch
+	return baseBranch, nil
 }
 
 func FindBaseAndFeatureBranches(
@@ -76,7 +127,11 @@ func FindBaseAndFeatureBranches(
 		return "", "", err
 	}
 
-	baseBranch = findBaseBranch(userBaseBranch, featureBranch)
+	baseBranch, err = findBaseBranch(ctx, userBaseBranch, featureBranch)
+	if err != nil {
+		return "", "", err
+	}
+
 	if baseBranch == "" {
 		return "", "", errors.New("base branch is required")
 	}
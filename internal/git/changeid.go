@@ -0,0 +1,117 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/waffleboot/giiter/internal/app"
+)
+
+// ChangeIDTrailer стабильный идентификатор изменения, переживает amend и rebase коммита
+const ChangeIDTrailer = "Change-Id"
+
+const changeIDHook = `#!/bin/sh
+# giiter: add Change-Id trailer to commit message
+
+grep -q -v -e '^#' -e '^[[:space:]]*$' "$1" || exit 0
+
+if grep -q -i '^Change-Id:' "$1"; then
+	exit 0
+fi
+
+id=$( (git var GIT_AUTHOR_IDENT; git var GIT_COMMITTER_IDENT; cat "$1") | git hash-object --stdin)
+
+git interpret-trailers --in-place --trailer "Change-Id: I${id}" "$1"
+`
+
+// InstallChangeIDHook устанавливает commit-msg hook, который добавляет Change-Id trailer
+func InstallChangeIDHook(ctx context.Context, force bool) (string, error) {
+	output, err := run(ctx, "rev-parse", "--git-path", "hooks/commit-msg")
+	if err != nil {
+		return "", err
+	}
+
+	hook := output[0]
+
+	if _, err := os.Stat(hook); err == nil && !force {
+		return "", fmt.Errorf("%s already exists, use --force to overwrite it", hook)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(hook), 0o755); err != nil {
+		return "", err
+	}
+
+	//nolint:gosec // hook должен быть исполняемым
+	if err := os.WriteFile(hook, []byte(changeIDHook), 0o755); err != nil {
+		return "", err
+	}
+
+	return hook, nil
+}
+
+func findFeatureConfig(featureBranch string) *app.FeatureBranch {
+	for i := range app.Config.Persistent.FeatureBranches {
+		if app.Config.Persistent.FeatureBranches[i].BranchName == featureBranch {
+			return &app.Config.Persistent.FeatureBranches[i]
+		}
+	}
+
+	return nil
+}
+
+// changeIDOfBranch ищет Change-Id, который ранее был сопоставлен review ветке
+func changeIDOfBranch(featureBranch, branchName string) string {
+	feature := findFeatureConfig(featureBranch)
+	if feature == nil {
+		return ""
+	}
+
+	for changeID, branch := range feature.Changes {
+		if branch == branchName {
+			return changeID
+		}
+	}
+
+	return ""
+}
+
+// RememberChangeID запоминает review ветку для Change-Id
+func RememberChangeID(featureBranch, changeID, branchName string) {
+	if changeID == "" {
+		return
+	}
+
+	feature := findFeatureConfig(featureBranch)
+	if feature == nil {
+		return
+	}
+
+	if feature.Changes == nil {
+		feature.Changes = make(map[string]string)
+	}
+
+	// у review ветки только один Change-Id
+	for id, branch := range feature.Changes {
+		if branch == branchName {
+			delete(feature.Changes, id)
+		}
+	}
+
+	feature.Changes[changeID] = branchName
+}
+
+// ForgetReviewBranch удаляет Change-Id удаленной review ветки
+func ForgetReviewBranch(featureBranch, branchName string) {
+	feature := findFeatureConfig(featureBranch)
+	if feature == nil {
+		return
+	}
+
+	for changeID, branch := range feature.Changes {
+		if branch == branchName {
+			delete(feature.Changes, changeID)
+		}
+	}
+}
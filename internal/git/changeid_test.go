@@ -0,0 +1,189 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/waffleboot/giiter/internal/app"
+)
+
+// newChangeIDRepo готовит feature ветку fa, коммит которой после rebase с правками изменил diff hash и subject,
+// а review/fa/1 указывает на его прежнюю версию
+func newChangeIDRepo(t *testing.T, reviewBody string) *fakeRepo {
+	f := newFakeRepo(t)
+	f.commits["abcdef0"] = fakeCommit{subject: "base", files: []string{"base.go"}}
+	f.commits["1000000"] = fakeCommit{parents: []string{"abcdef0"}, subject: "parser", body: reviewBody, files: []string{"parser.go"}}
+	f.commits["5555555"] = fakeCommit{
+		parents: []string{"abcdef0"}, subject: "parser: add lexer", body: "Change-Id: Iabc", files: []string{"lexer.go"},
+	}
+	f.branches = map[string]string{"master": "abcdef0", "fa": "5555555", "review/fa/1": "1000000"}
+
+	app.Config.UseChangeID = true
+	app.Config.Persistent.FeatureBranches = []app.FeatureBranch{{BaseBranch: "master", BranchName: "fa"}}
+
+	return f
+}
+
+func TestStateChangeID(t *testing.T) {
+	tests := []struct {
+		name       string
+		reviewBody string
+		changes    map[string]string
+		disabled   bool
+		matched    bool
+	}{
+		{name: "trailer of review commit", reviewBody: "Change-Id: Iabc", matched: true},
+		{name: "remembered review branch", changes: map[string]string{"Iabc": "review/fa/1"}, matched: true},
+		{name: "other change", reviewBody: "Change-Id: Idef"},
+		{name: "disabled", reviewBody: "Change-Id: Iabc", disabled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newChangeIDRepo(t, tt.reviewBody)
+			app.Config.Persistent.FeatureBranches[0].Changes = tt.changes
+			app.Config.UseChangeID = !tt.disabled
+
+			records, err := State(context.Background(), "master", "fa")
+			require.NoError(t, err)
+
+			if tt.matched {
+				require.Len(t, records, 1)
+				require.Equal(t, []string{"review/fa/1"}, records[0].ReviewBranchNames())
+				require.False(t, records[0].MatchedCommit())
+
+				return
+			}
+
+			// review ветка остается у старой записи
+			require.Len(t, records, 2)
+			require.True(t, records[0].IsNewCommit())
+			require.True(t, records[1].IsOldCommit())
+		})
+	}
+}
+
+func TestRefreshRemembersChangeID(t *testing.T) {
+	f := newChangeIDRepo(t, "Change-Id: Iabc")
+
+	_, err := Refresh(context.Background(), "master", "fa")
+	require.NoError(t, err)
+	require.Equal(t, "5555555", f.branches["review/fa/1"])
+	require.Equal(t, map[string]string{"Iabc": "review/fa/1"}, findFeatureConfig("fa").Changes)
+}
+
+func TestRememberChangeID(t *testing.T) {
+	newFakeRepo(t)
+	app.Config.Persistent.FeatureBranches = []app.FeatureBranch{{BaseBranch: "master", BranchName: "fa"}}
+
+	RememberChangeID("fa", "Iabc", "review/fa/1")
+	RememberChangeID("fa", "Idef", "review/fa/2")
+	RememberChangeID("fa", "", "review/fa/3")
+	RememberChangeID("fb", "Ixyz", "review/fb/1")
+	require.Equal(t, map[string]string{"Iabc": "review/fa/1", "Idef": "review/fa/2"}, findFeatureConfig("fa").Changes)
+
+	// у review ветки один Change-Id, например после split
+	RememberChangeID("fa", "Inew", "review/fa/1")
+	require.Equal(t, map[string]string{"Inew": "review/fa/1", "Idef": "review/fa/2"}, findFeatureConfig("fa").Changes)
+	require.Equal(t, "Inew", changeIDOfBranch("fa", "review/fa/1"))
+
+	ForgetReviewBranch("fa", "review/fa/1")
+	require.Equal(t, map[string]string{"Idef": "review/fa/2"}, findFeatureConfig("fa").Changes)
+	require.Empty(t, changeIDOfBranch("fa", "review/fa/1"))
+}
+
+func TestInstallChangeIDHook(t *testing.T) {
+	f := newFakeRepo(t)
+	hook := filepath.Join(t.TempDir(), "hooks", "commit-msg")
+	f.on("rev-parse --git-path hooks/commit-msg", hook)
+
+	installed, err := InstallChangeIDHook(context.Background(), false)
+	require.NoError(t, err)
+	require.Equal(t, hook, installed)
+
+	info, err := os.Stat(hook)
+	require.NoError(t, err)
+	require.NotZero(t, info.Mode()&0o100)
+
+	_, err = InstallChangeIDHook(context.Background(), false)
+	require.EqualError(t, err, hook+" already exists, use --force to overwrite it")
+
+	_, err = InstallChangeIDHook(context.Background(), true)
+	require.NoError(t, err)
+}
+
+func TestChangeIDHookScript(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	hook := filepath.Join(dir, "commit-msg")
+	require.NoError(t, os.WriteFile(hook, []byte(changeIDHook), 0o600))
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	git("init", "-q")
+	t.Setenv("GIT_AUTHOR_NAME", "giiter")
+	t.Setenv("GIT_AUTHOR_EMAIL", "giiter@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "giiter")
+	t.Setenv("GIT_COMMITTER_EMAIL", "giiter@example.com")
+
+	tests := []struct {
+		name    string
+		message string
+		added   bool
+	}{
+		{name: "subject", message: "parser: add lexer\n", added: true},
+		{name: "body", message: "parser: add lexer\n\nSplits input into tokens.\n", added: true},
+		{name: "already has trailer", message: "parser\n\nchange-id: Iabc\n"},
+		{name: "empty", message: ""},
+		{name: "comments only", message: "\n# Please enter the commit message\n#\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(dir, "COMMIT_EDITMSG")
+			require.NoError(t, os.WriteFile(file, []byte(tt.message), 0o600))
+
+			cmd := exec.Command("sh", hook, file)
+			cmd.Dir = dir
+
+			out, err := cmd.CombinedOutput()
+			require.NoError(t, err, string(out))
+
+			data, err := os.ReadFile(file)
+			require.NoError(t, err)
+
+			if !tt.added {
+				require.Equal(t, tt.message, string(data))
+
+				return
+			}
+
+			message := Message{Description: string(data)}
+			require.Len(t, message.Trailers(ChangeIDTrailer), 1)
+			require.Regexp(t, `^I[0-9a-f]{40}$`, message.Trailer(ChangeIDTrailer))
+
+			// повторный запуск, например при amend, trailer не дублирует
+			cmd = exec.Command("sh", hook, file)
+			cmd.Dir = dir
+			require.NoError(t, cmd.Run())
+
+			again, err := os.ReadFile(file)
+			require.NoError(t, err)
+			require.Equal(t, string(data), string(again))
+		})
+	}
+}
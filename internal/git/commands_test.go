@@ -0,0 +1,1001 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/waffleboot/giiter/internal/app"
+)
+
+const rebaseTodo = "-c sequence.editor=cp <todo> rebase -i abcdef0 fa"
+
+// rewriteFeature перестраивает fa из новых коммитов, как это сделал бы rebase
+func (f *fakeRepo) rewriteFeature(commits ...string) func() {
+	return func() {
+		f.branches["fa"] = f.add("abcdef0", commits...)
+	}
+}
+
+func TestRewriteStack(t *testing.T) {
+	tests := []struct {
+		name    string
+		rewrite func(records []Record) error
+		commits []string
+		todo    string
+		changes []string
+		err     string
+	}{
+		{
+			name: "move",
+			rewrite: func(records []Record) error {
+				return Move(context.Background(), "master", "fa", records, 0, 2)
+			},
+			commits: []string{"2000000 parser: add ast", "3000000 docs", "1000000 parser: add lexer", "4000000 fixup! docs"},
+			todo:    "pick 2222222\npick 3333333\npick 1111111\npick 4444444\n",
+			changes: []string{
+				rebaseTodo,
+				"branch -f review/fa/2 2000000",
+				"push origin --force -o merge_request.target=master review/fa/2:review/fa/2",
+				"branch -f review/fa/1 1000000",
+				"push origin --force -o merge_request.target=review/fa/2 review/fa/1:review/fa/1",
+			},
+		},
+		{
+			name: "drop",
+			rewrite: func(records []Record) error {
+				return Drop(context.Background(), "master", "fa", records, 2)
+			},
+			commits: []string{"1000000 parser: add lexer", "2000000 parser: add ast", "4000000 fixup! docs"},
+			todo:    "pick 1111111\npick 2222222\npick 4444444\n",
+			changes: []string{
+				rebaseTodo,
+				"branch -f review/fa/1 1000000",
+				"push origin --force -o merge_request.target=master review/fa/1:review/fa/1",
+				"branch -f review/fa/2 2000000",
+				"push origin --force -o merge_request.target=review/fa/1 review/fa/2:review/fa/2",
+			},
+		},
+		{
+			name: "squash",
+			rewrite: func(records []Record) error {
+				return Squash(context.Background(), "master", "fa", records, 2, 0)
+			},
+			commits: []string{"1000000 parser: add lexer", "2000000 parser: add ast", "4000000 fixup! docs"},
+			todo:    "pick 1111111\nfixup 3333333\npick 2222222\npick 4444444\n",
+			changes: []string{
+				rebaseTodo,
+				"branch -f review/fa/1 1000000",
+				"push origin --force -o merge_request.target=master review/fa/1:review/fa/1",
+				"branch -f review/fa/2 2000000",
+				"push origin --force -o merge_request.target=review/fa/1 review/fa/2:review/fa/2",
+			},
+		},
+		{
+			name: "move same record",
+			rewrite: func(records []Record) error {
+				return Move(context.Background(), "master", "fa", records, 1, 1)
+			},
+			err: "you point the same record",
+		},
+		{
+			name: "move old record",
+			rewrite: func(records []Record) error {
+				return Move(context.Background(), "master", "fa", records, 4, 0)
+			},
+			err: "record 5 has no feature commit",
+		},
+		{
+			name: "drop skipped record",
+			rewrite: func(records []Record) error {
+				return Drop(context.Background(), "master", "fa", records, 1, 3)
+			},
+			err: "record 4 is skipped, could not drop it",
+		},
+		{
+			name: "squash into old record",
+			rewrite: func(records []Record) error {
+				return Squash(context.Background(), "master", "fa", records, 2, 4)
+			},
+			err: "record 5 has no feature commit",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := testRecords(t)
+
+			f := newStackRepo(t)
+			f.onApply(rebaseTodo, f.rewriteFeature(tt.commits...))
+
+			err := tt.rewrite(records)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				require.Empty(t, f.changes)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.todo, f.todo)
+			require.Equal(t, tt.changes, f.changes)
+			require.Nil(t, app.Config.Persistent.Pending)
+		})
+	}
+}
+
+func TestRewriteStackRetarget(t *testing.T) {
+	var updates []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			// MR review/fa/2 смотрит не туда после прерванной перестройки
+			fmt.Fprintf(w, `[{"iid": 7, "title": "ast", "target_branch": "master"}]`)
+		case http.MethodPut:
+			require.NoError(t, r.ParseForm())
+			updates = append(updates, r.URL.Path+" "+r.PostForm.Get("target_branch"))
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+	defer server.Close()
+
+	records := testRecords(t)
+
+	f := newStackRepo(t)
+	app.Config.GitLab.URL = server.URL + "/api/v4"
+	app.Config.GitLab.Project = "group/project"
+	app.Config.GitLab.Token = "secret"
+
+	// docs переезжает в конец, коммиты с review ветками не меняются
+	f.onApply(rebaseTodo, func() {
+		f.branches["fa"] = f.add("2222222", "4000000 fixup! docs", "3000000 docs")
+	})
+
+	require.NoError(t, Move(context.Background(), "master", "fa", records, 2, 3))
+	require.Equal(t, "pick 1111111\npick 2222222\npick 4444444\npick 3333333\n", f.todo)
+	require.Equal(t, []string{"/api/v4/projects/group/project/merge_requests/7 review/fa/1"}, updates)
+}
+
+func TestRewriteStackRestack(t *testing.T) {
+	moved := []string{
+		rebaseTodo,
+		"branch -f review/fa/2 2000000",
+		"push origin --force -o merge_request.target=master review/fa/2:review/fa/2",
+		"branch -f review/fa/1 1000000",
+		"push origin --force -o merge_request.target=review/fa/2 review/fa/1:review/fa/1",
+		"rebase --onto fa 4444444 fb",
+	}
+
+	tests := []struct {
+		name     string
+		features []app.FeatureBranch
+		changes  []string
+	}{
+		{
+			name:     "child feature",
+			features: []app.FeatureBranch{{BaseBranch: "master", BranchName: "fa"}, {BaseBranch: "fa", BranchName: "fb"}},
+			changes:  append(moved, "checkout fa"),
+		},
+		{
+			// fa основана на fb только в старой конфигурации, fa второй раз не переносится
+			name:     "cycle",
+			features: []app.FeatureBranch{{BaseBranch: "fb", BranchName: "fa"}, {BaseBranch: "fa", BranchName: "fb"}},
+			changes:  append(moved, "checkout fb", "checkout fa"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := testRecords(t)
+
+			f := newStackRepo(t)
+			f.branches["fb"] = f.add("4444444", "5555555 printer")
+			app.Config.Persistent.FeatureBranches = tt.features
+
+			f.onApply(rebaseTodo, f.rewriteFeature(
+				"2000000 parser: add ast", "3000000 docs", "1000000 parser: add lexer", "4000000 fixup! docs"))
+			f.onApply("rebase --onto fa 4444444 fb", func() {
+				f.branches["fb"] = f.add("4000000", "5000000 printer")
+			})
+			f.on("checkout fb")
+			f.on("checkout fa")
+
+			require.NoError(t, Move(context.Background(), "master", "fa", records, 0, 2))
+			require.Equal(t, tt.changes, f.changes)
+			require.Equal(t, "5000000", f.branches["fb"])
+		})
+	}
+}
+
+func TestRewriteStackConflict(t *testing.T) {
+	records := testRecords(t)
+
+	f := newStackRepo(t)
+	f.fail(rebaseTodo, exitError(1))
+	f.on("rev-parse --git-path rebase-merge", t.TempDir())
+
+	err := Move(context.Background(), "master", "fa", records, 2, 0)
+	require.ErrorIs(t, err, ErrConflict)
+	require.Equal(t, []string{rebaseTodo, "rev-parse --git-path rebase-merge"}, f.changes)
+	require.Equal(t, &app.Operation{
+		Kind:          OperationRebase,
+		BaseBranch:    "master",
+		FeatureBranch: "fa",
+		Head:          "4444444",
+	}, app.Config.Persistent.Pending)
+}
+
+func TestAssign(t *testing.T) {
+	records := testRecords(t)
+	f := newStackRepo(t)
+
+	commit, branch, err := ResolveAssignment(records, "/^docs$/", "#3")
+	require.NoError(t, err)
+
+	branchName, err := records[branch].AnyReviewBranch()
+	require.NoError(t, err)
+
+	require.NoError(t, SwitchBranch(context.Background(), branchName, records[commit].CommitSHA()))
+	require.Equal(t, []string{
+		"branch -f review/fa/3 3333333",
+		"push origin --force review/fa/3:review/fa/3",
+	}, f.changes)
+}
+
+func TestEdit(t *testing.T) {
+	tests := []struct {
+		name    string
+		record  int
+		staged  bool
+		root    bool
+		stopped bool
+		changes []string
+		pending *app.Operation
+		err     string
+	}{
+		{
+			name:    "stop on commit",
+			record:  2,
+			stopped: true,
+			changes: []string{"diff --cached --quiet", "checkout --detach 3333333"},
+			pending: &app.Operation{
+				Kind:          OperationEdit,
+				BaseBranch:    "master",
+				FeatureBranch: "fa",
+				Commit:        "3333333",
+				Head:          "4444444",
+			},
+		},
+		{
+			name:   "fixup staged changes",
+			record: 1,
+			staged: true,
+			changes: []string{
+				"diff --cached --quiet",
+				"branch --show-current",
+				"commit --no-verify --fixup=2222222",
+				"-c sequence.editor=: rebase -i --autosquash --autostash 2222222~ fa",
+			},
+		},
+		{
+			name:   "fixup root commit",
+			record: 1,
+			staged: true,
+			root:   true,
+			changes: []string{
+				"diff --cached --quiet",
+				"branch --show-current",
+				"commit --no-verify --fixup=2222222",
+				"-c sequence.editor=: rebase -i --autosquash --autostash --root fa",
+			},
+		},
+		{
+			name:   "old record",
+			record: 4,
+			err:    "could not edit commit without feature commit",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := testRecords(t)
+
+			f := newStackRepo(t)
+			f.on("diff --cached --quiet")
+			f.on("checkout --detach 3333333")
+			f.on("branch --show-current", "fa")
+			f.on("commit --no-verify --fixup=2222222")
+			f.on("-c sequence.editor=: rebase -i --autosquash --autostash 2222222~ fa")
+			f.on("-c sequence.editor=: rebase -i --autosquash --autostash --root fa")
+
+			if tt.staged {
+				f.fail("diff --cached --quiet", exitError(1))
+			}
+
+			if tt.root {
+				commit := f.commits["2222222"]
+				commit.parents = nil
+				f.commits["2222222"] = commit
+			}
+
+			stopped, err := Edit(context.Background(), "master", "fa", &records[tt.record])
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				require.Empty(t, f.changes)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.stopped, stopped)
+			require.Equal(t, tt.changes, f.changes)
+			require.Equal(t, tt.pending, app.Config.Persistent.Pending)
+		})
+	}
+}
+
+func TestEditGroup(t *testing.T) {
+	f := newStackRepo(t)
+	f.commits["5000000"] = fakeCommit{
+		parents: []string{"abcdef0"}, subject: "lexer", body: "Review-Group: parser", files: []string{"lexer.go"},
+	}
+	f.commits["6000000"] = fakeCommit{
+		parents: []string{"5000000"}, subject: "ast", body: "Review-Group: parser", files: []string{"ast.go"},
+	}
+	f.add("6000000", "7000000 docs")
+	f.branches["fg"] = "7000000"
+
+	records, err := State(context.Background(), "master", "fg")
+	require.NoError(t, err)
+	require.Equal(t, []string{"5000000", "6000000"}, records[0].Commits())
+
+	f.on("diff --cached --quiet")
+	f.onApply("-c sequence.editor=cp <todo> rebase -i 5000000~ fg", func() { f.rebasing = true })
+
+	stopped, err := Edit(context.Background(), "master", "fg", &records[0])
+	require.NoError(t, err)
+	require.True(t, stopped)
+	require.Equal(t, "edit 5000000\nedit 6000000\npick 7000000\n", f.todo)
+	require.Equal(t, OperationEdit, app.Config.Persistent.Pending.Kind)
+
+	// незакоммиченные правки rebase --continue не примет
+	f.on("status --porcelain --untracked-files=no", " M lexer.go")
+
+	_, err = Continue(context.Background())
+	require.EqualError(t, err, "commit your changes with git commit --amend or stash them, then run giiter continue")
+	require.Equal(t, OperationEdit, app.Config.Persistent.Pending.Kind)
+
+	// rebase останавливается на втором коммите группы
+	f.on("status --porcelain --untracked-files=no")
+	f.on("-c core.editor=: rebase --continue")
+
+	_, err = Continue(context.Background())
+	require.ErrorIs(t, err, ErrEditStopped)
+	require.Equal(t, OperationEdit, app.Config.Persistent.Pending.Kind)
+
+	f.onApply("-c core.editor=: rebase --continue", func() { f.rebasing = false })
+
+	op, err := Continue(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "fg", op.FeatureBranch)
+	require.Nil(t, app.Config.Persistent.Pending)
+	require.Equal(t, []string{
+		"diff --cached --quiet",
+		"-c sequence.editor=cp <todo> rebase -i 5000000~ fg",
+		"status --porcelain --untracked-files=no",
+		"status --porcelain --untracked-files=no",
+		"-c core.editor=: rebase --continue",
+		"status --porcelain --untracked-files=no",
+		"-c core.editor=: rebase --continue",
+	}, f.changes)
+}
+
+func TestContinueEditRebaseRefused(t *testing.T) {
+	f := newStackRepo(t)
+	f.on("status --porcelain --untracked-files=no")
+	f.fail("-c sequence.editor=: rebase -i --autosquash --onto HEAD 3333333 fa", exitError(1))
+
+	op := &app.Operation{
+		Kind:          OperationEdit,
+		BaseBranch:    "master",
+		FeatureBranch: "fa",
+		Commit:        "3333333",
+		Head:          "4444444",
+	}
+	app.Config.Persistent.Pending = op
+
+	// rebase не начался, edit остается в силе, чтобы giiter abort вернул feature ветку
+	_, err := Continue(context.Background())
+	require.Error(t, err)
+	require.Same(t, op, app.Config.Persistent.Pending)
+	require.Equal(t, OperationEdit, op.Kind)
+}
+
+func TestAbortEdit(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   []string
+		rebasing bool
+		changes  []string
+	}{
+		{
+			name: "clean tree",
+			changes: []string{
+				"status --porcelain --untracked-files=no",
+				"checkout fa",
+				"reset --soft 4444444",
+			},
+		},
+		{
+			name:   "dirty tree",
+			status: []string{" M docs.go"},
+			changes: []string{
+				"status --porcelain --untracked-files=no",
+				"stash push -m giiter edit of 3333333",
+				"checkout fa",
+				"reset --soft 4444444",
+			},
+		},
+		{
+			name:     "dirty review group",
+			status:   []string{" M docs.go"},
+			rebasing: true,
+			changes: []string{
+				"status --porcelain --untracked-files=no",
+				"stash push -m giiter edit of 3333333",
+				"rebase --abort",
+				"checkout fa",
+				"reset --soft 4444444",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newStackRepo(t)
+			f.on("status --porcelain --untracked-files=no", tt.status...)
+			f.on("stash push -m giiter edit of 3333333")
+			f.on("checkout fa")
+			f.on("reset --soft 4444444")
+			f.onApply("rebase --abort", func() { f.rebasing = false })
+			f.rebasing = tt.rebasing
+
+			app.Config.Persistent.Pending = &app.Operation{
+				Kind:          OperationEdit,
+				BaseBranch:    "master",
+				FeatureBranch: "fa",
+				Commit:        "3333333",
+				Head:          "4444444",
+			}
+
+			require.NoError(t, Abort(context.Background()))
+			require.Nil(t, app.Config.Persistent.Pending)
+			require.Equal(t, tt.changes, f.changes)
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		record  int
+		status  []string
+		changes []string
+		pending *app.Operation
+		err     string
+	}{
+		{
+			name:    "stop for staging",
+			record:  2,
+			changes: []string{"status --porcelain --untracked-files=no", "checkout --detach 3333333", "reset -q HEAD~"},
+			pending: &app.Operation{
+				Kind:          OperationSplit,
+				BaseBranch:    "master",
+				FeatureBranch: "fa",
+				Commit:        "3333333",
+				Head:          "4444444",
+				TargetBranch:  "review/fa/2",
+			},
+		},
+		{
+			name:    "record with review",
+			record:  1,
+			changes: []string{"status --porcelain --untracked-files=no", "checkout --detach 2222222", "reset -q HEAD~"},
+			pending: &app.Operation{
+				Kind:          OperationSplit,
+				BaseBranch:    "master",
+				FeatureBranch: "fa",
+				Commit:        "2222222",
+				Head:          "4444444",
+				ReviewBranch:  "review/fa/2",
+				TargetBranch:  "review/fa/1",
+			},
+		},
+		{
+			name:    "dirty tree",
+			record:  2,
+			status:  []string{" M docs.md"},
+			changes: []string{"status --porcelain --untracked-files=no"},
+			err:     "working tree has uncommitted changes",
+		},
+		{name: "skipped record", record: 3, err: "could not split skipped commit"},
+		{name: "old record", record: 4, err: "could not split commit without feature commit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := testRecords(t)
+
+			f := newStackRepo(t)
+			f.on("status --porcelain --untracked-files=no", tt.status...)
+			f.on("checkout --detach " + records[tt.record].CommitSHA())
+			f.on("reset -q HEAD~")
+
+			stopped, err := Split(context.Background(), "master", "fa", records, tt.record, nil)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				require.Equal(t, tt.changes, f.changes)
+				require.Nil(t, app.Config.Persistent.Pending)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.True(t, stopped)
+			require.Equal(t, tt.changes, f.changes)
+			require.Equal(t, tt.pending, app.Config.Persistent.Pending)
+		})
+	}
+}
+
+func TestContinueSplit(t *testing.T) {
+	f := newStackRepo(t)
+	f.commits["2222222"] = fakeCommit{
+		parents: []string{"1111111"},
+		subject: "parser: add ast",
+		body:    "Builds tree from tokens.\n\nReview-Group: parser\nChange-Id: I1111\nLabel: parser",
+		files:   []string{"ast.go", "printer.go"},
+	}
+
+	saved := newChangeID
+	newChangeID = func() (string, error) { return "I2222", nil }
+
+	t.Cleanup(func() { newChangeID = saved })
+
+	f.fail("diff --cached --quiet", exitError(1))
+	f.onApply("commit -q --no-verify -C 2222222", func() {
+		f.commits["5555555"] = fakeCommit{parents: []string{"1111111"}, subject: "parser: add ast"}
+		f.branches["HEAD"] = "5555555"
+	})
+	f.on("add -A -- :(top)ast.go :(top)printer.go")
+	f.onApply("commit -q --no-verify -m parser: add ast (2)\n\n"+
+		"Builds tree from tokens.\n\nReview-Group: parser\nLabel: parser\nChange-Id: I2222", func() {
+		f.commits["6666666"] = fakeCommit{parents: []string{"5555555"}, subject: "parser: add ast (2)"}
+		f.branches["HEAD"] = "6666666"
+	})
+	f.on("rebase --onto HEAD 2222222 fa")
+
+	app.Config.Persistent.Pending = &app.Operation{
+		Kind:          OperationSplit,
+		BaseBranch:    "master",
+		FeatureBranch: "fa",
+		Commit:        "2222222",
+		Head:          "4444444",
+	}
+
+	op, err := Continue(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "6666666", op.SplitSecond)
+	require.Nil(t, app.Config.Persistent.Pending)
+	require.Equal(t, []string{
+		"diff --cached --quiet",
+		"commit -q --no-verify -C 2222222",
+		"add -A -- :(top)ast.go :(top)printer.go",
+		"diff --cached --quiet",
+		"commit -q --no-verify -m parser: add ast (2)\n\n" +
+			"Builds tree from tokens.\n\nReview-Group: parser\nLabel: parser\nChange-Id: I2222",
+		"rebase --onto HEAD 2222222 fa",
+	}, f.changes)
+}
+
+func TestSecondPartMessage(t *testing.T) {
+	saved := newChangeID
+	newChangeID = func() (string, error) { return "I2222", nil }
+
+	t.Cleanup(func() { newChangeID = saved })
+
+	tests := []struct {
+		name    string
+		message Message
+		want    string
+	}{
+		{name: "subject only", message: Message{Subject: "parser"}, want: "parser (2)"},
+		{
+			name:    "trailers stay",
+			message: Message{Subject: "parser", Description: "Body.\n\nReview-Group: parser\nLabel: x\n"},
+			want:    "parser (2)\n\nBody.\n\nReview-Group: parser\nLabel: x",
+		},
+		{
+			name:    "new change id",
+			message: Message{Subject: "parser", Description: "Review-Group: parser\nchange-id: I1111"},
+			want:    "parser (2)\n\nReview-Group: parser\nChange-Id: I2222",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, err := secondPartMessage(tt.message)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, message)
+		})
+	}
+}
+
+func TestContinueSplitAfterConflict(t *testing.T) {
+	f := newStackRepo(t)
+	f.add("1111111", "5555555 parser: add ast", "6666666 parser: add ast (2)")
+
+	// в ревью была печать ast, она попала во вторую, меньшую часть
+	f.on("diff --no-color --unified=0 2222222~ 2222222",
+		"diff --git a/printer.go b/printer.go", "--- a/printer.go", "+++ b/printer.go", "+func print() {}")
+	f.on("diff --no-color --unified=0 5555555~ 5555555",
+		"diff --git a/ast.go b/ast.go", "--- a/ast.go", "+++ b/ast.go", "+type Node struct{}", "+type Tree struct{}")
+	f.on("diff --no-color --unified=0 6666666~ 6666666",
+		"diff --git a/printer.go b/printer.go", "--- a/printer.go", "+++ b/printer.go", "+func print() {}")
+
+	// split остановился на конфликте, runRebase сделал из него rebase
+	app.Config.Persistent.Pending = &app.Operation{
+		Kind:          OperationRebase,
+		BaseBranch:    "master",
+		FeatureBranch: "fa",
+		Commit:        "2222222",
+		Head:          "4444444",
+		ReviewBranch:  "review/fa/2",
+		TargetBranch:  "review/fa/1",
+		SplitFirst:    "5555555",
+		SplitSecond:   "6666666",
+	}
+
+	op, err := Continue(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "fa", op.FeatureBranch)
+	require.Nil(t, app.Config.Persistent.Pending)
+	require.Equal(t, "6666666", f.branches["review/fa/2"])
+	require.Equal(t, "5555555", f.branches["review/fa/4"])
+	require.Equal(t, []string{
+		"diff --no-color --unified=0 2222222~ 2222222",
+		"diff --no-color --unified=0 5555555~ 5555555",
+		"diff --no-color --unified=0 6666666~ 6666666",
+		"branch review/fa/4 5555555",
+		"branch -f review/fa/2 6666666",
+		"push origin --force -o merge_request.target=review/fa/4 review/fa/2:review/fa/2",
+		"push -o merge_request.create -o merge_request.target=review/fa/1 -o merge_request.title=Draft: parser: add ast " +
+			"-o merge_request.label=review origin review/fa/4:review/fa/4",
+	}, f.changes)
+}
+
+func TestPatchOverlap(t *testing.T) {
+	review := map[string][]string{
+		"ast.go":     {"+type Node struct{}", "-type Node int"},
+		"printer.go": {"+func print() {}"},
+	}
+
+	lines, files := patchOverlap(review, map[string][]string{
+		"ast.go":  {"+type Node struct{}", "+type Tree struct{}"},
+		"main.go": {"+func main() {}"},
+	})
+	require.Equal(t, 1, lines)
+	require.Equal(t, 1, files)
+
+	lines, files = patchOverlap(review, map[string][]string{"printer.go": {"+func dump() {}"}})
+	require.Equal(t, 0, lines)
+	require.Equal(t, 1, files)
+}
+
+func TestDiff(t *testing.T) {
+	records := testRecords(t)
+
+	f := newStackRepo(t)
+	f.on("diff 1111111~..2222222 --color=always", "diff --git a/x b/x")
+
+	var buf bytes.Buffer
+
+	require.NoError(t, Diff(context.Background(), &buf, records[0].DiffBase(), records[1].CommitSHA(), "--color=always"))
+	require.Equal(t, "diff --git a/x b/x\n", buf.String())
+	require.Equal(t, []string{"diff 1111111~..2222222 --color=always"}, f.changes)
+}
+
+func TestInterdiff(t *testing.T) {
+	records := testRecords(t)
+
+	tests := []struct {
+		name   string
+		record *Record
+		diff   string
+		err    string
+	}{
+		{
+			name:   "commit",
+			record: &records[1],
+			diff:   "range-diff --creation-factor=100 2222222~..2222222 2222222~..2222222",
+		},
+		{
+			name: "review group",
+			record: func() *Record {
+				// review ветка указывает на прежнюю версию группы 5000001..6000001
+				lexer := &commit{SHA: "5000000", Parents: []string{"abcdef0"}, Message: Message{Subject: "lexer", Description: "Review-Group: parser"}}
+				ast := &commit{SHA: "6000000", Parents: []string{"5000000"}, Message: Message{Subject: "ast", Description: "Review-Group: parser"}}
+
+				record := newRecord(lexer)
+				record.joinGroup(ast)
+				record.addReviewBranch(newReviewBranch(1, Branch{CommitSHA: "6000001", BranchName: "review/fg/1"}))
+
+				return &record
+			}(),
+			diff: "range-diff --creation-factor=100 abcdef0..6000001 abcdef0..6000000",
+		},
+		{name: "new record", record: &records[2], err: "record has no review branch yet, use diff without --interdiff"},
+		{name: "old record", record: &records[4], err: "record is not in the feature branch anymore"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newStackRepo(t)
+			f.commits["5000001"] = fakeCommit{parents: []string{"abcdef0"}, subject: "lexer", body: "Review-Group: parser"}
+			f.commits["6000001"] = fakeCommit{parents: []string{"5000001"}, subject: "ast", body: "Review-Group: parser"}
+			f.on(tt.diff)
+
+			err := Interdiff(context.Background(), &bytes.Buffer{}, tt.record)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, []string{tt.diff}, f.changes)
+		})
+	}
+}
+
+func TestSetDraft(t *testing.T) {
+	tests := []struct {
+		name    string
+		title   string
+		draft   bool
+		changed bool
+		update  string
+	}{
+		{name: "ready", title: "Draft: parser", changed: true, update: "parser"},
+		{name: "already ready", title: "parser"},
+		{name: "draft", title: "parser", draft: true, changed: true, update: "Draft: parser"},
+		{name: "already draft", title: "[Draft] parser", draft: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var update string
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+				switch r.Method {
+				case http.MethodGet:
+					require.Equal(t, "/api/v4/projects/group%2Fproject/merge_requests", r.URL.EscapedPath())
+					require.Equal(t, "review/fa/1", r.URL.Query().Get("source_branch"))
+					fmt.Fprintf(w, `[{"iid": 7, "title": %q}]`, tt.title)
+				case http.MethodPut:
+					require.Equal(t, "/api/v4/projects/group%2Fproject/merge_requests/7", r.URL.EscapedPath())
+					require.NoError(t, r.ParseForm())
+					update = r.PostForm.Get("title")
+					fmt.Fprint(w, `{}`)
+				default:
+					t.Errorf("unexpected %s %s", r.Method, r.URL)
+				}
+			}))
+			defer server.Close()
+
+			newFakeRepo(t)
+			app.Config.GitLab.URL = server.URL + "/api/v4"
+			app.Config.GitLab.Project = "group/project"
+			app.Config.GitLab.Token = "secret"
+
+			changed, err := SetDraft(context.Background(), "review/fa/1", tt.draft)
+			require.NoError(t, err)
+			require.Equal(t, tt.changed, changed)
+			require.Equal(t, tt.update, update)
+		})
+	}
+}
+
+func TestCreateMergeRequestByAPI(t *testing.T) {
+	yes := true
+
+	var (
+		f       *fakeRepo
+		created url.Values
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v4/users" && r.URL.Query().Get("username") == "bob":
+			fmt.Fprint(w, `[{"id": 2}]`)
+		case r.URL.Path == "/api/v4/users" && r.URL.Query().Get("search") == "carol@example.com":
+			fmt.Fprint(w, `[{"id": 3, "public_email": "Carol@example.com"}]`)
+		case r.URL.Path == "/api/v4/users":
+			fmt.Fprint(w, `[]`)
+		case r.URL.Path == "/api/v4/projects/group/project/milestones":
+			fmt.Fprint(w, `[]`)
+		case r.Method == http.MethodPost:
+			// MR создается после push review ветки
+			require.Equal(t, []string{"push origin review/fa/1:review/fa/1"}, f.changes)
+			require.NoError(t, r.ParseForm())
+			created = r.PostForm
+			fmt.Fprint(w, `{}`)
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL)
+		}
+	}))
+	defer server.Close()
+
+	req := MergeRequest{
+		Title:        "parser",
+		SourceBranch: "review/fa/1",
+		TargetBranch: "master",
+		Options: app.MergeRequestOptions{
+			Reviewers: []string{"bob", "carol@example.com", "erin@example.com"},
+			Squash:    &yes,
+		},
+	}
+
+	setup := func(t *testing.T) {
+		f = newFakeRepo(t)
+		app.Config.MergeRequestAPI = true
+		app.Config.GitLab.URL = server.URL + "/api/v4"
+		app.Config.GitLab.Project = "group/project"
+		app.Config.GitLab.Token = "secret"
+	}
+
+	t.Run("create", func(t *testing.T) {
+		setup(t)
+
+		require.NoError(t, CreateMergeRequest(context.Background(), req))
+		require.Equal(t, []string{"2", "3"}, created["reviewer_ids[]"])
+		require.Equal(t, "true", created.Get("squash"))
+		require.Empty(t, created.Get("remove_source_branch"))
+	})
+
+	t.Run("unknown milestone", func(t *testing.T) {
+		setup(t)
+
+		req := req
+		req.Options.Milestone = "v9"
+
+		require.EqualError(t, CreateMergeRequest(context.Background(), req), "gitlab milestone v9 not found")
+		require.Empty(t, f.changes, "review branch must not be pushed without MR")
+	})
+}
+
+// newFetchRepo готовит rebase --fetch: в origin/master влит коммит записи "parser: add lexer"
+func newFetchRepo(t *testing.T) *fakeRepo {
+	f := newStackRepo(t)
+	f.on("rev-parse --verify --quiet --short refs/remotes/origin/master", "abcdef0")
+	f.onApply("fetch origin +refs/heads/master:refs/remotes/origin/master", func() {
+		f.add("abcdef0", "b000000 parser: add lexer")
+		f.branches["origin/master"] = "b000000"
+	})
+	f.on("merge-base --is-ancestor master origin/master")
+	f.on("branch --show-current", "fa")
+	f.on("merge-base --fork-point origin/master fa", "abcdef0")
+
+	return f
+}
+
+func TestFetchRebase(t *testing.T) {
+	tests := []struct {
+		name      string
+		forkPoint bool
+	}{
+		{name: "fork point", forkPoint: true},
+		// reflog origin/master не помог, fork point это merge-base со старой локальной master
+		{name: "old base fallback"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFetchRepo(t)
+			if !tt.forkPoint {
+				f.fail("merge-base --fork-point origin/master fa", exitError(1))
+			}
+
+			// git выбрасывает коммит, который уже есть в master
+			f.onApply("rebase --onto master abcdef0 fa", func() {
+				f.branches["fa"] = f.add("b000000", "2aaaaaa parser: add ast", "3aaaaaa docs", "4aaaaaa fixup! docs")
+			})
+
+			report, err := FetchRebase(context.Background(), "master", "fa", true)
+			require.NoError(t, err)
+			require.Nil(t, app.Config.Persistent.Pending)
+			require.Nil(t, report.Conflict)
+			require.Equal(t, "b000000", f.branches["master"])
+
+			var landed, rebased []string
+			for i := range report.Landed {
+				landed = append(landed, report.Landed[i].CommitSHA())
+			}
+
+			for i := range report.Rebased {
+				rebased = append(rebased, report.Rebased[i].CommitSHA())
+			}
+
+			require.Equal(t, []string{"1111111"}, landed)
+			require.Equal(t, []string{"2222222", "3333333"}, rebased)
+			require.Contains(t, f.changes, "rebase --onto master abcdef0 fa")
+		})
+	}
+}
+
+func TestFetchRebaseConflict(t *testing.T) {
+	tests := []struct {
+		name      string
+		resumable bool
+	}{
+		{name: "resumable", resumable: true},
+		{name: "aborted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFetchRepo(t)
+			f.replies["rebase --onto master abcdef0 fa"] = fakeReply{err: exitError(1), apply: func() {
+				f.rebasing = true
+				f.branches["REBASE_HEAD"] = "2222222"
+			}}
+			// --cherry-mark отмечает коммит, изменения которого уже есть в master
+			f.on("log --cherry-mark --right-only --no-merges --format=%m%h master...4444444",
+				"=1111111", ">2222222", ">3333333", ">4444444")
+			f.onApply("rebase --abort", func() { f.rebasing = false })
+			f.on("checkout fa")
+			f.on("reset --soft 4444444")
+
+			report, err := FetchRebase(context.Background(), "master", "fa", tt.resumable)
+			require.ErrorIs(t, err, ErrConflict)
+			require.Equal(t, "2222222", report.Conflict.CommitSHA())
+			require.Len(t, report.Landed, 1)
+			require.Equal(t, "1111111", report.Landed[0].CommitSHA())
+
+			if !tt.resumable {
+				require.Nil(t, app.Config.Persistent.Pending)
+				require.Equal(t, []string{
+					"rebase --abort",
+					"checkout fa",
+					"reset --soft 4444444",
+				}, f.changes[len(f.changes)-3:])
+
+				return
+			}
+
+			require.NotContains(t, f.changes, "rebase --abort")
+
+			require.True(t, app.Config.Persistent.Pending.Landed)
+
+			// после giiter continue make снимает draft с нижнего MR, для этого операция помнит влитые записи
+			f.onApply("-c core.editor=: rebase --continue", func() {
+				f.rebasing = false
+				f.branches["fa"] = f.add("b000000", "2aaaaaa parser: add ast", "3aaaaaa docs", "4aaaaaa fixup! docs")
+			})
+
+			op, err := Continue(context.Background())
+			require.NoError(t, err)
+			require.True(t, op.Landed)
+			require.Nil(t, app.Config.Persistent.Pending)
+		})
+	}
+}
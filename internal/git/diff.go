@@ -4,13 +4,15 @@ import (
 	"context"
 	"crypto/sha256"
 	"database/sql"
+	"errors"
 	"fmt"
-	"os"
-	"os/exec"
+	"io"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/waffleboot/giiter/internal/app"
+	"github.com/waffleboot/giiter/internal/output"
 )
 
 func changedFiles(ctx context.Context, sha string, runner Runner) ([]string, error) {
@@ -19,11 +21,16 @@ func changedFiles(ctx context.Context, sha string, runner Runner) ([]string, err
 		return nil, err
 	}
 
-	if len(files) == 1 {
+	if len(files) <= 1 {
 		return nil, nil
 	}
 
-	files = files[1:]
+	return unquoteFiles(files[1:])
+}
+
+func unquoteFiles(files []string) ([]string, error) {
+	var err error
+
 	for i := range files {
 		if files[i][0] == '"' {
 			files[i], err = strconv.Unquote(files[i])
@@ -36,8 +43,132 @@ func changedFiles(ctx context.Context, sha string, runner Runner) ([]string, err
 	return files, nil
 }
 
+// treeChangedFiles возвращает файлы измененные между двумя коммитами
+func treeChangedFiles(ctx context.Context, from, to string) ([]string, error) {
+	files, err := run(ctx, "diff-tree", "-r", "--name-only", from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return unquoteFiles(files)
+}
+
+// commitFiles возвращает файлы измененные коммитом,
+// для merge коммита изменения считаются относительно первого родителя
+func commitFiles(ctx context.Context, sha string) ([]string, error) {
+	parents, err := commitParents(ctx, sha)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parents) > 1 {
+		return treeChangedFiles(ctx, parents[0], sha)
+	}
+
+	return changedFiles(ctx, sha, gitRunner)
+}
+
 func diffHash(ctx context.Context, sha string) (sql.NullString, error) {
-	files, err := changedFiles(ctx, sha, runner{})
+	parents, err := commitParents(ctx, sha)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	if len(parents) > 1 {
+		// combined diff merge коммита бессмысленен, сравниваем с первым родителем
+		return hashDiff(ctx, sha, parents[0])
+	}
+
+	return hashDiff(ctx, sha, "")
+}
+
+// recordDiffHash считает хеш изменений записи, для группы коммитов от родителя первого коммита
+func recordDiffHash(ctx context.Context, record *Record) (sql.NullString, error) {
+	if record.diffBase != "" {
+		return hashDiff(ctx, record.CommitSHA(), record.diffBase)
+	}
+
+	return diffHash(ctx, record.CommitSHA())
+}
+
+// DiffHash возвращает хеш изменений записи, пустой если изменений нет
+func DiffHash(ctx context.Context, record *Record) (string, error) {
+	hash, err := recordDiffHash(ctx, record)
+
+	return hash.String, err
+}
+
+// reviewDiffHash считает хеш изменений коммита review ветки,
+// если коммит последний в review группе, то вместе с предыдущими коммитами группы.
+// Группа ищется только до merge-base с baseBranch, коммиты base ветки тоже могут нести trailer группы,
+// пустой baseBranch не ограничивает поиск
+func reviewDiffHash(ctx context.Context, commit *commit, baseBranch string) (sql.NullString, string, error) {
+	group := commit.Message.Trailer(GroupTrailer)
+	if group == "" || len(commit.Parents) == 0 {
+		hash, err := diffHash(ctx, commit.SHA)
+
+		return hash, "", err
+	}
+
+	var stop string
+
+	if baseBranch != "" {
+		output, err := run(ctx, "merge-base", commit.SHA, baseBranch)
+		if err != nil {
+			return sql.NullString{}, "", err
+		}
+
+		if len(output) > 0 {
+			stop = output[0]
+		}
+	}
+
+	first := commit
+
+	// merge-base выводит полный SHA, а родители коммита сокращены
+	for len(first.Parents) > 0 && (stop == "" || !strings.HasPrefix(stop, first.Parents[0])) {
+		parent, err := findCommit(ctx, first.Parents[0])
+		if err != nil {
+			return sql.NullString{}, "", err
+		}
+
+		if parent.Message.Trailer(GroupTrailer) != group {
+			break
+		}
+
+		first = parent
+	}
+
+	if len(first.Parents) == 0 {
+		hash, err := diffHash(ctx, commit.SHA)
+
+		return hash, "", err
+	}
+
+	hash, err := hashDiff(ctx, commit.SHA, first.Parents[0])
+
+	return hash, first.Parents[0], err
+}
+
+// topPathspec задает путь от корня рабочей копии, чтобы giiter работал из любого каталога
+func topPathspec(file string) string {
+	return ":(top)" + file
+}
+
+// hashDiff считает хеш изменений коммита sha относительно from,
+// пустой from означает обычный diff коммита
+func hashDiff(ctx context.Context, sha, from string) (sql.NullString, error) {
+	var (
+		files []string
+		err   error
+	)
+
+	if from == "" {
+		files, err = changedFiles(ctx, sha, gitRunner)
+	} else {
+		files, err = treeChangedFiles(ctx, from, sha)
+	}
+
 	if err != nil {
 		return sql.NullString{}, err
 	}
@@ -51,12 +182,23 @@ func diffHash(ctx context.Context, sha string) (sql.NullString, error) {
 	for i := range files {
 		file := files[i]
 
-		diff, err := run(ctx, "diff-tree", "--unified=0", "-c", sha, "--", file)
-		if err != nil {
-			return sql.NullString{}, err
-		}
+		var diff []string
+
+		if from == "" {
+			diff, err = run(ctx, "diff-tree", "--unified=0", "-c", sha, "--", topPathspec(file))
+			if err != nil {
+				return sql.NullString{}, err
+			}
 
-		diff = diff[2:]
+			diff = diff[2:]
+		} else {
+			diff, err = run(ctx, "diff-tree", "--unified=0", from, sha, "--", topPathspec(file))
+			if err != nil {
+				return sql.NullString{}, err
+			}
+
+			diff = diff[1:]
+		}
 
 		if strings.HasPrefix(diff[0], "new file") {
 			hash.Write([]byte("new file"))
@@ -83,10 +225,10 @@ func diffHash(ctx context.Context, sha string) (sql.NullString, error) {
 		}
 
 		if app.Config.Debug {
-			fmt.Printf("--- diff %s %s\n", sha, file)
+			output.Infof("--- diff %s %s\n", sha, file)
 
 			for _, line := range diff {
-				fmt.Println(line)
+				output.Infof("%s\n", line)
 			}
 		}
 	}
@@ -96,19 +238,217 @@ func diffHash(ctx context.Context, sha string) (sql.NullString, error) {
 	strSum := fmt.Sprintf("%x", sum)
 
 	if app.Config.Debug {
-		fmt.Printf("Commit: %s DiffHash: %s\n", sha, strSum)
+		output.Infof("Commit: %s DiffHash: %s\n", sha, strSum)
 	}
 
 	return sql.NullString{String: strSum, Valid: true}, nil
 }
 
-func Diff(ctx context.Context, commitSHA string, args ...string) error {
-	cmdArgs := []string{"diff", commitSHA + "~.." + commitSHA}
+// DiffLines возвращает diff между коммитами для просмотра в интерфейсе
+func DiffLines(ctx context.Context, fromSHA, toSHA string) ([]string, error) {
+	return run(ctx, "diff", "--stat", "--patch", fromSHA+".."+toSHA)
+}
 
-	cmd := exec.CommandContext(ctx, "git", append(cmdArgs, args...)...)
+// Diff выводит в w изменения между коммитами, ошибки git идут в stderr
+func Diff(ctx context.Context, w io.Writer, fromSHA, toSHA string, args ...string) error {
+	return runTo(ctx, w, append([]string{"diff", fromSHA + ".." + toSHA}, args...)...)
+}
 
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
+// Interdiff выводит в w range-diff между review коммитом записи и коммитом feature ветки,
+// то есть то, что увидят ревьюеры после следующего push
+func Interdiff(ctx context.Context, w io.Writer, record *Record, args ...string) error {
+	switch {
+	case !record.HasReview():
+		return errors.New("record has no review branch yet, use diff without --interdiff")
+	case record.IsOldCommit():
+		return errors.New("record is not in the feature branch anymore")
+	}
+
+	// у review группы изменения считаются от начала группы, а не от последнего коммита
+	reviewBase, err := commitBase(ctx, record.reviewSHA, reviewBranchBase(firstReviewBranch(record)))
+	if err != nil {
+		return err
+	}
+
+	// запись это одна пара коммитов, поэтому они сравниваются, даже если изменения сильно отличаются
+	cmdArgs := []string{"range-diff", "--creation-factor=100"}
+	cmdArgs = append(cmdArgs, args...)
+	cmdArgs = append(cmdArgs, reviewBase+".."+record.reviewSHA, record.DiffBase()+".."+record.featureSHA)
+
+	return runTo(ctx, w, cmdArgs...)
+}
+
+// InterdiffStat сравнивает изменения записи в review ветке и в feature ветке:
+// rebased значит, что изменения те же и после make review ветка поменяет только базу,
+// иначе stat показывает по файлам, какие строки изменений добавились и пропали по сравнению с review веткой,
+// изменения базы в stat не попадают, потому что сравниваются сами патчи, а не деревья коммитов
+func InterdiffStat(ctx context.Context, record *Record) (rebased bool, stat []string, err error) {
+	review, err := findCommit(ctx, record.reviewSHA)
+	if err != nil {
+		return false, nil, err
+	}
+
+	// diff hash не учитывает содержимое новых файлов, поэтому сравниваем сами изменения
+	_, reviewBase, err := reviewDiffHash(ctx, review, reviewBranchBase(firstReviewBranch(record)))
+	if err != nil {
+		return false, nil, err
+	}
+
+	if reviewBase == "" {
+		reviewBase = record.reviewSHA + "~"
+	}
+
+	reviewPatch, err := patchLines(ctx, reviewBase, record.reviewSHA)
+	if err != nil {
+		return false, nil, err
+	}
+
+	featurePatch, err := patchLines(ctx, record.DiffBase(), record.featureSHA)
+	if err != nil {
+		return false, nil, err
+	}
+
+	if strings.Join(reviewPatch, "\n") == strings.Join(featurePatch, "\n") {
+		return true, nil, nil
+	}
+
+	return false, interdiffStat(reviewPatch, featurePatch), nil
+}
+
+// interdiffStat сравнивает патчи по файлам: + строки изменений, которых не было в review ветке, - пропавшие
+func interdiffStat(reviewPatch, featurePatch []string) []string {
+	reviewFiles, featureFiles := patchFiles(reviewPatch), patchFiles(featurePatch)
+
+	files := make([]string, 0, len(reviewFiles)+len(featureFiles))
+
+	for file := range featureFiles {
+		files = append(files, file)
+	}
+
+	for file := range reviewFiles {
+		if _, ok := featureFiles[file]; !ok {
+			files = append(files, file)
+		}
+	}
+
+	sort.Strings(files)
+
+	var (
+		stat                  []string
+		insertions, deletions int
+	)
+
+	for _, file := range files {
+		added, removed := countChanges(reviewFiles[file], featureFiles[file])
+		if added == 0 && removed == 0 {
+			continue
+		}
+
+		insertions += added
+		deletions += removed
+
+		stat = append(stat, fmt.Sprintf(" %s | %d %s%s", file, added+removed,
+			strings.Repeat("+", added), strings.Repeat("-", removed)))
+	}
+
+	if len(stat) == 0 {
+		// патчи отличаются только порядком строк или заголовками файлов, например режимом
+		return []string{" only line order or file modes changed"}
+	}
+
+	return append(stat, fmt.Sprintf(" %d files changed, %d insertions(+), %d deletions(-)", len(stat), insertions, deletions))
+}
+
+// patchFiles раскладывает строки изменений из вывода patchLines по файлам, заголовки файлов пропускаются
+func patchFiles(patch []string) map[string][]string {
+	files := make(map[string][]string)
+
+	var (
+		file   string
+		header bool
+	)
+
+	for _, line := range patch {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			file = line[strings.LastIndex(line, " b/")+len(" b/"):]
+			files[file] = nil
+			header = true
+		case header:
+			// заголовок заканчивается на +++, у бинарных файлов и смены режима строк изменений нет
+			header = !strings.HasPrefix(line, "+++ ")
+		default:
+			files[file] = append(files[file], line)
+		}
+	}
+
+	return files
+}
+
+// countChanges считает строки, которые есть только в одном из патчей, порядок строк не учитывается
+func countChanges(review, feature []string) (added, removed int) {
+	counts := make(map[string]int, len(review))
+
+	for _, line := range review {
+		counts[line]++
+	}
+
+	for _, line := range feature {
+		if counts[line] > 0 {
+			counts[line]--
+
+			continue
+		}
+
+		added++
+	}
+
+	for _, n := range counts {
+		removed += n
+	}
+
+	return added, removed
+}
+
+// patchLines возвращает изменения без номеров строк и хешей blob, они меняются при rebase
+func patchLines(ctx context.Context, from, to string) ([]string, error) {
+	diff, err := run(ctx, "diff", "--no-color", "--unified=0", from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]string, 0, len(diff))
+
+	for _, line := range diff {
+		if strings.HasPrefix(line, "index ") || strings.HasPrefix(line, "@@") {
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines, nil
+}
+
+// Pager возвращает pager для вывода git: GIT_PAGER, core.pager или PAGER
+func Pager(ctx context.Context) (string, error) {
+	pager, err := run(ctx, "var", "GIT_PAGER")
+	if err != nil {
+		return "", err
+	}
+
+	if len(pager) == 0 {
+		return "", nil
+	}
+
+	return pager[0], nil
+}
+
+// runTo запускает git с выводом в w, например в pager, без буферизации вывода
+func runTo(ctx context.Context, w io.Writer, args ...string) error {
+	if app.Config.Verbose {
+		output.Infof("git %s\n", strings.Join(args, " "))
+	}
 
-	return cmd.Run()
+	return gitRunner.RunTo(ctx, w, args...)
 }
@@ -0,0 +1,66 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterdiffStat(t *testing.T) {
+	header := func(file string) []string {
+		return []string{"diff --git a/" + file + " b/" + file, "--- a/" + file, "+++ b/" + file}
+	}
+
+	patch := func(parts ...[]string) []string {
+		var lines []string
+		for _, part := range parts {
+			lines = append(lines, part...)
+		}
+
+		return lines
+	}
+
+	tests := []struct {
+		name    string
+		review  []string
+		feature []string
+		stat    []string
+	}{
+		{
+			name:    "changed line",
+			review:  patch(header("a.go"), []string{"-old", "+new"}),
+			feature: patch(header("a.go"), []string{"-old", "+newer"}),
+			stat:    []string{" a.go | 2 +-", " 1 files changed, 1 insertions(+), 1 deletions(-)"},
+		},
+		{
+			name:    "new file in feature",
+			review:  patch(header("a.go"), []string{"+a"}),
+			feature: patch(header("a.go"), []string{"+a"}, header("b.go"), []string{"+b1", "+b2"}),
+			stat:    []string{" b.go | 2 ++", " 1 files changed, 2 insertions(+), 0 deletions(-)"},
+		},
+		{
+			name:    "file dropped from feature",
+			review:  patch(header("a.go"), []string{"+a"}, header("b.go"), []string{"+b"}),
+			feature: patch(header("a.go"), []string{"+a"}),
+			stat:    []string{" b.go | 1 -", " 1 files changed, 0 insertions(+), 1 deletions(-)"},
+		},
+		{
+			name:    "moved lines",
+			review:  patch(header("a.go"), []string{"+a", "+b"}),
+			feature: patch(header("a.go"), []string{"+b", "+a"}),
+			stat:    []string{" only line order or file modes changed"},
+		},
+		{
+			name:    "new mode only",
+			review:  patch(header("a.go"), []string{"+a"}),
+			feature: patch([]string{"diff --git a/a.go b/a.go", "old mode 100644", "new mode 100755"}, header("a.go")[1:], []string{"+a"}),
+			stat:    []string{" only line order or file modes changed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.stat, interdiffStat(tt.review, tt.feature))
+		})
+	}
+}
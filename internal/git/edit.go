@@ -0,0 +1,414 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/waffleboot/giiter/internal/app"
+	"github.com/waffleboot/giiter/internal/output"
+)
+
+const (
+	OperationEdit   = "edit"
+	OperationRebase = "rebase"
+)
+
+var ErrConflict = errors.New("rebase stopped on conflict, resolve it and run giiter continue or giiter abort")
+
+// ErrEditStopped rebase остановился на следующем коммите review группы, это не ошибка, а очередной шаг edit
+var ErrEditStopped = errors.New("stopped at the next commit of the review group, amend it and run giiter continue")
+
+func pendingOperation() (*app.Operation, error) {
+	op := app.Config.Persistent.Pending
+	if op == nil {
+		return nil, errors.New("no giiter operation in progress")
+	}
+
+	return op, nil
+}
+
+func checkNoPendingOperation() error {
+	if op := app.Config.Persistent.Pending; op != nil {
+		return fmt.Errorf("%s of %s is in progress, use giiter continue or giiter abort", op.Kind, op.FeatureBranch)
+	}
+
+	return nil
+}
+
+func revParse(ctx context.Context, rev string) (string, error) {
+	output, err := run(ctx, "rev-parse", "--short", rev)
+	if err != nil {
+		return "", err
+	}
+
+	return output[0], nil
+}
+
+func hasStagedChanges(ctx context.Context) (bool, error) {
+	_, err := run(ctx, "diff", "--cached", "--quiet")
+	if err == nil {
+		return false, nil
+	}
+
+	var errExit *exec.ExitError
+	if errors.As(err, &errExit) && errExit.ExitCode() == 1 {
+		return true, nil
+	}
+
+	return false, err
+}
+
+func rebaseInProgress(ctx context.Context) (bool, error) {
+	for _, dir := range []string{"rebase-merge", "rebase-apply"} {
+		output, err := run(ctx, "rev-parse", "--git-path", dir)
+		if err != nil {
+			return false, err
+		}
+
+		if _, err := os.Stat(output[0]); err == nil {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// runRebase запускает rebase от имени giiter, при конфликте rebase остается незавершенным,
+// а операция запоминается чтобы ее можно было продолжить.
+// Если rebase не начался, то операция остается такой, какой была до него
+func runRebase(ctx context.Context, op *app.Operation, args ...string) error {
+	kind, pending := op.Kind, app.Config.Persistent.Pending
+
+	op.Kind = OperationRebase
+	app.Config.Persistent.Pending = op
+
+	_, errRebase := run(ctx, args...)
+
+	inProgress, err := rebaseInProgress(ctx)
+	if err != nil {
+		return err
+	}
+
+	if errRebase == nil && inProgress {
+		// на строке edit rebase -i останавливается без ошибки, это очередной шаг edit
+		op.Kind = OperationEdit
+
+		return ErrEditStopped
+	}
+
+	if errRebase == nil {
+		app.Config.Persistent.Pending = nil
+
+		return nil
+	}
+
+	var errRun ErrRun
+	if errors.As(errRebase, &errRun) {
+		errRun.log()
+	}
+
+	if inProgress {
+		return ErrConflict
+	}
+
+	op.Kind = kind
+	app.Config.Persistent.Pending = pending
+
+	return errRebase
+}
+
+// rebaseFrom возвращает аргумент rebase -i, с которого в план попадает коммит sha,
+// у корневого коммита нет родителя, поэтому нужен --root
+func rebaseFrom(ctx context.Context, sha string) (string, error) {
+	parents, err := commitParents(ctx, sha)
+	if err != nil {
+		return "", err
+	}
+
+	if len(parents) == 0 {
+		return "--root", nil
+	}
+
+	return sha + "~", nil
+}
+
+// Edit останавливается на коммите записи чтобы его можно было изменить, у review группы на каждом ее коммите.
+// Если в индексе есть изменения, то они добавляются в коммит записи как fixup
+// и остальные коммиты сразу перестраиваются, тогда stopped будет false
+func Edit(ctx context.Context, baseBranch, featureBranch string, record *Record) (stopped bool, err error) {
+	if err := checkNoPendingOperation(); err != nil {
+		return false, err
+	}
+
+	if record.IsOldCommit() {
+		return false, errors.New("could not edit commit without feature commit")
+	}
+
+	head, err := revParse(ctx, featureBranch)
+	if err != nil {
+		return false, err
+	}
+
+	op := &app.Operation{
+		Kind:          OperationEdit,
+		BaseBranch:    baseBranch,
+		FeatureBranch: featureBranch,
+		Commit:        record.CommitSHA(),
+		Head:          head,
+	}
+
+	staged, err := hasStagedChanges(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if !staged && len(record.Commits()) > 1 {
+		return editGroup(ctx, op, record.Commits())
+	}
+
+	if !staged {
+		if _, err := run(ctx, "checkout", "--detach", op.Commit); err != nil {
+			return false, err
+		}
+
+		app.Config.Persistent.Pending = op
+
+		return true, nil
+	}
+
+	currentBranch, err := getCurrentBranch(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if currentBranch != featureBranch {
+		return false, fmt.Errorf("checkout %s to fixup staged changes", featureBranch)
+	}
+
+	if _, err := run(ctx, "commit", "--no-verify", "--fixup="+op.Commit); err != nil {
+		return false, err
+	}
+
+	from, err := rebaseFrom(ctx, op.Commit)
+	if err != nil {
+		return false, err
+	}
+
+	if err := runRebase(ctx, op,
+		"-c", "sequence.editor=:", "rebase", "-i", "--autosquash", "--autostash", from, featureBranch,
+	); err != nil {
+		return false, err
+	}
+
+	return false, restackChildren(ctx, featureBranch, head)
+}
+
+// editGroup запускает rebase, который останавливается на каждом коммите review группы,
+// giiter continue переходит к следующему коммиту группы, а после последнего перестраивает остальные коммиты
+func editGroup(ctx context.Context, op *app.Operation, group []string) (bool, error) {
+	commits, err := allCommits(ctx, op.BaseBranch, op.FeatureBranch)
+	if err != nil {
+		return false, err
+	}
+
+	start := -1
+
+	for i := range commits {
+		if commits[i] == group[0] {
+			start = i
+
+			break
+		}
+	}
+
+	if start < 0 {
+		return false, fmt.Errorf("commit %s is not in %s", group[0], op.FeatureBranch)
+	}
+
+	inGroup := make(map[string]bool, len(group))
+	for _, sha := range group {
+		inGroup[sha] = true
+	}
+
+	var todo strings.Builder
+
+	for _, sha := range commits[start:] {
+		parents, err := commitParents(ctx, sha)
+		if err != nil {
+			return false, err
+		}
+
+		if len(parents) > 1 {
+			return false, fmt.Errorf("commit %s is merge commit, rewrite feature branch manually", sha)
+		}
+
+		action := todoPick
+		if inGroup[sha] {
+			action = todoEdit
+		}
+
+		fmt.Fprintf(&todo, "%s %s\n", action, sha)
+	}
+
+	file, err := writeTodoFile(todo.String())
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(file)
+
+	from, err := rebaseFrom(ctx, group[0])
+	if err != nil {
+		return false, err
+	}
+
+	err = runRebase(ctx, op, "-c", fmt.Sprintf("sequence.editor=cp '%s'", file), "rebase", "-i", from, op.FeatureBranch)
+	if errors.Is(err, ErrEditStopped) {
+		return true, nil
+	}
+
+	return false, err
+}
+
+// Continue продолжает операцию giiter и возвращает ее после успешного завершения
+func Continue(ctx context.Context) (*app.Operation, error) {
+	op, err := pendingOperation()
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Kind {
+	case OperationEdit:
+		if err := continueEdit(ctx, op); err != nil {
+			return nil, err
+		}
+	case OperationSplit:
+		if err := finishSplit(ctx, op); err != nil {
+			return nil, err
+		}
+	case OperationRebase:
+		inProgress, err := rebaseInProgress(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if inProgress {
+			if err := runRebase(ctx, op, "-c", "core.editor=:", "rebase", "--continue"); err != nil {
+				return nil, err
+			}
+		}
+
+		// rebase split остановился на конфликте, review ветки частей еще не созданы
+		if err := finishSplitReview(ctx, op); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown operation %s", op.Kind)
+	}
+
+	app.Config.Persistent.Pending = nil
+
+	return op, restackChildren(ctx, op.FeatureBranch, op.Head)
+}
+
+// continueEdit переносит остальные коммиты feature ветки на измененный коммит,
+// у review группы rebase уже идет и переходит к следующему коммиту группы
+func continueEdit(ctx context.Context, op *app.Operation) error {
+	dirty, err := dirtyTree(ctx)
+	if err != nil {
+		return err
+	}
+
+	// rebase не начнется, а правки не попадут в коммит
+	if dirty {
+		return errors.New("commit your changes with git commit --amend or stash them, then run giiter continue")
+	}
+
+	inProgress, err := rebaseInProgress(ctx)
+	if err != nil {
+		return err
+	}
+
+	if inProgress {
+		return runRebase(ctx, op, "-c", "core.editor=:", "rebase", "--continue")
+	}
+
+	return runRebase(ctx, op,
+		"-c", "sequence.editor=:", "rebase", "-i", "--autosquash", "--onto", "HEAD", op.Commit, op.FeatureBranch,
+	)
+}
+
+// Abort отменяет операцию giiter и возвращает feature ветку в исходное состояние,
+// изменения добавленные через fixup остаются в индексе
+func Abort(ctx context.Context) error {
+	op, err := pendingOperation()
+	if err != nil {
+		return err
+	}
+
+	inProgress, err := rebaseInProgress(ctx)
+	if err != nil {
+		return err
+	}
+
+	// правки остановленного edit не должны пропасть и не должны мешать checkout,
+	// rebase --abort review группы их тоже выбросил бы
+	if op.Kind == OperationEdit {
+		if err := stashEdit(ctx, op); err != nil {
+			return err
+		}
+	}
+
+	if inProgress {
+		if _, err := run(ctx, "rebase", "--abort"); err != nil {
+			return err
+		}
+	}
+
+	// split оставляет в рабочей директории изменения коммита, они есть в feature ветке
+	checkout := []string{"checkout"}
+	if op.Kind == OperationSplit {
+		checkout = append(checkout, "--force")
+	}
+
+	if _, err := run(ctx, append(checkout, op.FeatureBranch)...); err != nil {
+		return err
+	}
+
+	if op.Head != "" {
+		if _, err := run(ctx, "reset", "--soft", op.Head); err != nil {
+			return err
+		}
+	}
+
+	app.Config.Persistent.Pending = nil
+
+	return nil
+}
+
+// stashEdit убирает в stash незакоммиченные правки коммита, на котором остановился edit
+func stashEdit(ctx context.Context, op *app.Operation) error {
+	dirty, err := dirtyTree(ctx)
+	if err != nil || !dirty {
+		return err
+	}
+
+	if _, err := run(ctx, "stash", "push", "-m", "giiter edit of "+op.Commit); err != nil {
+		return err
+	}
+
+	output.Infof("uncommitted changes of edit are saved in git stash\n")
+
+	return nil
+}
+
+// dirtyTree сообщает об изменениях отслеживаемых файлов в индексе или рабочей директории
+func dirtyTree(ctx context.Context) (bool, error) {
+	status, err := run(ctx, "status", "--porcelain", "--untracked-files=no")
+
+	return len(status) > 0, err
+}
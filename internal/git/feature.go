@@ -0,0 +1,233 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/waffleboot/giiter/internal/app"
+)
+
+// branchExists проверяет, что локальная ветка существует
+func branchExists(ctx context.Context, branchName string) (bool, error) {
+	_, err := run(ctx, "rev-parse", "--verify", "--quiet", "refs/heads/"+branchName)
+	if err == nil {
+		return true, nil
+	}
+
+	var errExit *exec.ExitError
+	if errors.As(err, &errExit) && errExit.ExitCode() == 1 {
+		return false, nil
+	}
+
+	return false, err
+}
+
+func validateFeatureBranch(ctx context.Context, featureBranch string) error {
+	if isProtectedBranch(featureBranch) {
+		return fmt.Errorf("%s could not be feature branch", featureBranch)
+	}
+
+	if strings.HasPrefix(featureBranch, Prefix) {
+		return fmt.Errorf("review branch %s could not be feature branch", featureBranch)
+	}
+
+	return checkBranchExists(ctx, featureBranch)
+}
+
+func checkBranchExists(ctx context.Context, branchName string) error {
+	ok, err := branchExists(ctx, branchName)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return fmt.Errorf("branch %s not found", branchName)
+	}
+
+	return nil
+}
+
+// checkNoCycle проверяет, что цепочка base веток от baseBranch не приходит к featureBranch
+func checkNoCycle(baseBranch, featureBranch string) error {
+	seen := make(map[string]bool)
+
+	for branch := baseBranch; branch != ""; {
+		if branch == featureBranch {
+			return fmt.Errorf("%s could not be based on %s, features form a cycle", featureBranch, baseBranch)
+		}
+
+		if seen[branch] {
+			return nil
+		}
+
+		seen[branch] = true
+
+		feature := findFeatureConfig(branch)
+		if feature == nil {
+			return nil
+		}
+
+		branch = feature.BaseBranch
+	}
+
+	return nil
+}
+
+// AddFeature регистрирует feature ветку или меняет ее base ветку
+func AddFeature(ctx context.Context, baseBranch, featureBranch string) error {
+	if err := validateFeatureBranch(ctx, featureBranch); err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(baseBranch, Prefix) {
+		return fmt.Errorf("review branch %s could not be base branch", baseBranch)
+	}
+
+	if err := checkBranchExists(ctx, baseBranch); err != nil {
+		return err
+	}
+
+	if err := checkNoCycle(baseBranch, featureBranch); err != nil {
+		return err
+	}
+
+	if feature := findFeatureConfig(featureBranch); feature != nil {
+		feature.BaseBranch = baseBranch
+
+		return nil
+	}
+
+	app.Config.Persistent.FeatureBranches = append(app.Config.Persistent.FeatureBranches, app.FeatureBranch{
+		BaseBranch: baseBranch,
+		BranchName: featureBranch,
+	})
+
+	return nil
+}
+
+func checkFeatureIdle(featureBranch string) error {
+	if op := app.Config.Persistent.Pending; op != nil && op.FeatureBranch == featureBranch {
+		return fmt.Errorf("%s is in progress on %s, run giiter continue or giiter abort", op.Kind, featureBranch)
+	}
+
+	return nil
+}
+
+// RemoveFeature удаляет review ветки feature ветки и забывает ее, сама feature ветка остается
+func RemoveFeature(ctx context.Context, featureBranch string) error {
+	if findFeatureConfig(featureBranch) == nil {
+		return fmt.Errorf("feature %s not found", featureBranch)
+	}
+
+	if err := checkFeatureIdle(featureBranch); err != nil {
+		return err
+	}
+
+	if children := childFeatures(featureBranch); len(children) > 0 {
+		return fmt.Errorf("features %s are based on %s, remove them first", strings.Join(children, ","), featureBranch)
+	}
+
+	reviewBranches, err := AllReviewBranches(ctx, featureBranch)
+	if err != nil {
+		return err
+	}
+
+	for _, branch := range reviewBranches {
+		if err := DeleteBranch(ctx, branch.BranchName()); err != nil {
+			return err
+		}
+	}
+
+	features := app.Config.Persistent.FeatureBranches[:0]
+
+	for _, item := range app.Config.Persistent.FeatureBranches {
+		if item.BranchName != featureBranch {
+			features = append(features, item)
+		}
+	}
+
+	app.Config.Persistent.FeatureBranches = features
+
+	return nil
+}
+
+// RenameFeature переносит конфигурацию и локальные review ветки на новое имя feature ветки,
+// саму ветку нужно переименовать заранее через git branch -m.
+// GitLab не меняет source ветку MR, поэтому если review ветки уже отправлены, переименование отклоняется
+func RenameFeature(ctx context.Context, oldBranch, newBranch string) error {
+	feature := findFeatureConfig(oldBranch)
+	if feature == nil {
+		return fmt.Errorf("feature %s not found", oldBranch)
+	}
+
+	if findFeatureConfig(newBranch) != nil {
+		return fmt.Errorf("feature %s already exists", newBranch)
+	}
+
+	if err := checkFeatureIdle(oldBranch); err != nil {
+		return err
+	}
+
+	if err := validateFeatureBranch(ctx, newBranch); err != nil {
+		return err
+	}
+
+	if err := checkNotPushed(ctx, oldBranch); err != nil {
+		return err
+	}
+
+	reviewBranches, err := AllReviewBranches(ctx, oldBranch)
+	if err != nil {
+		return err
+	}
+
+	renamed := make(map[string]string, len(reviewBranches))
+
+	for _, branch := range reviewBranches {
+		newName := ReviewBranchName(newBranch, branch.id)
+
+		if _, err := run(ctx, "branch", "-m", branch.BranchName(), newName); err != nil {
+			return err
+		}
+
+		renamed[branch.BranchName()] = newName
+	}
+
+	for changeID, branch := range feature.Changes {
+		if newName, ok := renamed[branch]; ok {
+			feature.Changes[changeID] = newName
+		}
+	}
+
+	for i := range app.Config.Persistent.FeatureBranches {
+		if app.Config.Persistent.FeatureBranches[i].BaseBranch == oldBranch {
+			app.Config.Persistent.FeatureBranches[i].BaseBranch = newBranch
+		}
+	}
+
+	feature.BranchName = newBranch
+
+	return nil
+}
+
+// checkNotPushed проверяет, что на сервере нет review веток feature ветки, при выключенном push их там быть не может
+func checkNotPushed(ctx context.Context, featureBranch string) error {
+	if !app.Config.EnableGitPush {
+		return nil
+	}
+
+	remote, err := run(ctx, "ls-remote", "--heads", "origin", "refs/heads/"+Prefix+featureBranch+"/*")
+	if err != nil {
+		return err
+	}
+
+	if len(remote) > 0 {
+		return fmt.Errorf("review branches of %s are pushed and merge requests can not change source branch, "+
+			"run giiter feature remove %s and giiter make after the rename", featureBranch, featureBranch)
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package git
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/waffleboot/giiter/internal/app"
+)
+
+func TestFindBaseAndFeatureBranches(t *testing.T) {
+	setup := func(t *testing.T) {
+		f := newFakeRepo(t)
+		f.branches = map[string]string{"master": "abcdef0", "fa": "abcdef0", "fb": "abcdef0", "review/fa/1": "abcdef0"}
+		app.Config.Persistent.FeatureBranches = []app.FeatureBranch{
+			{BaseBranch: "master", BranchName: "fa"},
+			{BaseBranch: "fa", BranchName: "fb"},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		base    string
+		feature string
+		want    string
+		err     string
+	}{
+		{name: "registered", feature: "fb", want: "fa"},
+		{name: "same base", base: "fa", feature: "fb", want: "fa"},
+		{name: "rebase", base: "master", feature: "fb", want: "master"},
+		{name: "not registered", feature: "fc", err: "base branch is required"},
+		{name: "missing branch", base: "master", feature: "fc", err: "branch fc not found"},
+		{name: "cycle", base: "fb", feature: "fa", err: "fa could not be based on fb, features form a cycle"},
+		{name: "review base", base: "review/fa/1", feature: "fb", err: "review branch review/fa/1 could not be base branch"},
+		{name: "review feature", base: "master", feature: "review/fa/1", err: "review branch review/fa/1 could not be feature branch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup(t)
+
+			base, feature, err := FindBaseAndFeatureBranches(context.Background(), tt.base, tt.feature)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				require.Len(t, app.Config.Persistent.FeatureBranches, 2, "failed -b must not change features")
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, base)
+			require.Equal(t, tt.feature, feature)
+			require.Equal(t, tt.want, findFeatureConfig(tt.feature).BaseBranch)
+		})
+	}
+}
+
+func TestFeatureTreeCycle(t *testing.T) {
+	newFakeRepo(t)
+
+	// такой цикл мог остаться от прежних версий, которые не проверяли -b
+	app.Config.Persistent.FeatureBranches = []app.FeatureBranch{
+		{BaseBranch: "master", BranchName: "fa"},
+		{BaseBranch: "fc", BranchName: "fb"},
+		{BaseBranch: "fb", BranchName: "fc"},
+	}
+
+	var names []string
+	for _, root := range FeatureTree() {
+		require.Empty(t, root.Children)
+		names = append(names, root.BranchName)
+	}
+
+	require.Equal(t, []string{"fa", "fb", "fc"}, names)
+}
+
+func TestRenameFeature(t *testing.T) {
+	setup := func(t *testing.T) *fakeRepo {
+		f := newFakeRepo(t)
+		f.branches = map[string]string{"master": "abcdef0", "fc": "abcdef0", "fb": "abcdef0", "review/fa/1": "abcdef0"}
+		app.Config.Persistent.FeatureBranches = []app.FeatureBranch{
+			{BaseBranch: "master", BranchName: "fa", Changes: map[string]string{"I1": "review/fa/1"}},
+			{BaseBranch: "fa", BranchName: "fb"},
+		}
+
+		return f
+	}
+
+	t.Run("local review branches", func(t *testing.T) {
+		f := setup(t)
+		f.on("ls-remote --heads origin refs/heads/review/fa/*")
+		f.on("branch -m review/fa/1 review/fc/1")
+
+		require.NoError(t, RenameFeature(context.Background(), "fa", "fc"))
+		require.Equal(t, []app.FeatureBranch{
+			{BaseBranch: "master", BranchName: "fc", Changes: map[string]string{"I1": "review/fc/1"}},
+			{BaseBranch: "fc", BranchName: "fb"},
+		}, app.Config.Persistent.FeatureBranches)
+	})
+
+	t.Run("pushed review branches", func(t *testing.T) {
+		f := setup(t)
+		f.on("ls-remote --heads origin refs/heads/review/fa/*", "abcdef0 refs/heads/review/fa/1")
+
+		require.EqualError(t, RenameFeature(context.Background(), "fa", "fc"),
+			"review branches of fa are pushed and merge requests can not change source branch, "+
+				"run giiter feature remove fa and giiter make after the rename")
+		require.Equal(t, []string{"ls-remote --heads origin refs/heads/review/fa/*"}, f.changes)
+		require.Equal(t, "fa", app.Config.Persistent.FeatureBranches[0].BranchName)
+	})
+}
@@ -0,0 +1,170 @@
+package git
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/waffleboot/giiter/internal/app"
+)
+
+// commitFilter возвращает причину по которой коммит не нужно ревьюить,
+// пустая строка означает что коммит проходит фильтр
+type commitFilter func(ctx context.Context, commit *commit) (string, error)
+
+func commitFilters(baseBranch string) ([]commitFilter, error) {
+	cfg := app.Config.Shared.Filters
+
+	filters := []commitFilter{
+		mergeFromBaseFilter(baseBranch),
+		emptyCommitFilter,
+	}
+
+	if cfg.SkipFixup {
+		filters = append(filters, fixupCommitFilter)
+	}
+
+	if len(cfg.Subjects) > 0 {
+		subjects := make([]*regexp.Regexp, 0, len(cfg.Subjects))
+
+		for _, expr := range cfg.Subjects {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "subject filter %q", expr)
+			}
+
+			subjects = append(subjects, re)
+		}
+
+		filters = append(filters, subjectFilter(subjects))
+	}
+
+	if len(cfg.Paths) > 0 {
+		for _, pattern := range cfg.Paths {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, errors.WithMessagef(err, "path filter %q", pattern)
+			}
+		}
+
+		filters = append(filters, pathFilter(cfg.Paths))
+	}
+
+	return filters, nil
+}
+
+func skipReason(ctx context.Context, filters []commitFilter, commit *commit) (string, error) {
+	for _, filter := range filters {
+		reason, err := filter(ctx, commit)
+		if err != nil {
+			return "", err
+		}
+
+		if reason != "" {
+			return reason, nil
+		}
+	}
+
+	return "", nil
+}
+
+// mergeFromBaseReason причина пропуска merge base ветки, за ней следует имя base ветки
+const mergeFromBaseReason = "merge from "
+
+// isMergeFromBase проверяет, что запись это merge base ветки, пропущенный mergeFromBaseFilter
+func isMergeFromBase(record *Record) bool {
+	return record.IsMergeCommit() && strings.HasPrefix(record.SkipReason(), mergeFromBaseReason)
+}
+
+// mergeFromBaseFilter отбрасывает merge base ветки в feature ветку,
+// такие коммиты не несут своих изменений и ревьюить их не нужно
+func mergeFromBaseFilter(baseBranch string) commitFilter {
+	return func(ctx context.Context, commit *commit) (string, error) {
+		if !commit.isMerge() {
+			return "", nil
+		}
+
+		for _, parent := range commit.Parents[1:] {
+			fromBase, err := isAncestor(ctx, parent, baseBranch)
+			if err != nil {
+				return "", err
+			}
+
+			if !fromBase {
+				return "", nil
+			}
+		}
+
+		return mergeFromBaseReason + baseBranch, nil
+	}
+}
+
+func emptyCommitFilter(ctx context.Context, commit *commit) (string, error) {
+	files, err := commitFiles(ctx, commit.SHA)
+	if err != nil {
+		return "", err
+	}
+
+	if len(files) == 0 {
+		return "empty", nil
+	}
+
+	return "", nil
+}
+
+func fixupCommitFilter(_ context.Context, commit *commit) (string, error) {
+	for _, prefix := range []string{"fixup! ", "squash! ", "amend! "} {
+		if strings.HasPrefix(commit.Message.Subject, prefix) {
+			return strings.TrimSuffix(prefix, "! "), nil
+		}
+	}
+
+	return "", nil
+}
+
+func subjectFilter(subjects []*regexp.Regexp) commitFilter {
+	return func(_ context.Context, commit *commit) (string, error) {
+		for _, re := range subjects {
+			if re.MatchString(commit.Message.Subject) {
+				return "subject " + re.String(), nil
+			}
+		}
+
+		return "", nil
+	}
+}
+
+// pathFilter отбрасывает коммиты все файлы которых подходят под шаблоны,
+// шаблон сравнивается и с полным путем и с именем файла
+func pathFilter(patterns []string) commitFilter {
+	return func(ctx context.Context, commit *commit) (string, error) {
+		files, err := commitFiles(ctx, commit.SHA)
+		if err != nil {
+			return "", err
+		}
+
+		for _, file := range files {
+			if !matchAnyPath(patterns, file) {
+				return "", nil
+			}
+		}
+
+		return "paths", nil
+	}
+}
+
+func matchAnyPath(patterns []string, file string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, file); ok {
+			return true
+		}
+
+		if ok, _ := path.Match(pattern, path.Base(file)); ok {
+			return true
+		}
+	}
+
+	return false
+}
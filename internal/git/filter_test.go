@@ -0,0 +1,116 @@
+package git
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/waffleboot/giiter/internal/app"
+)
+
+func TestCommitFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters app.Filters
+		count   int
+		err     string
+	}{
+		{name: "default", count: 2},
+		{name: "fixup", filters: app.Filters{SkipFixup: true}, count: 3},
+		{name: "all", filters: app.Filters{SkipFixup: true, Subjects: []string{"^wip"}, Paths: []string{"*.md"}}, count: 5},
+		{name: "invalid subject", filters: app.Filters{Subjects: []string{"^wip", "(docs"}}, err: `subject filter "(docs"`},
+		{name: "invalid path", filters: app.Filters{Paths: []string{"[docs"}}, err: `path filter "[docs"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := app.Config.Shared.Filters
+			app.Config.Shared.Filters = tt.filters
+
+			defer func() { app.Config.Shared.Filters = saved }()
+
+			filters, err := commitFilters("master")
+			if tt.err != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, filters, tt.count)
+		})
+	}
+}
+
+func TestFixupCommitFilter(t *testing.T) {
+	tests := []struct {
+		subject string
+		reason  string
+	}{
+		{subject: "fixup! parser: add lexer", reason: "fixup"},
+		{subject: "squash! parser: add lexer", reason: "squash"},
+		{subject: "amend! parser: add lexer", reason: "amend"},
+		{subject: "parser: fixup! lexer"},
+		{subject: "fixup!parser"},
+		{subject: "Fixup! parser"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.subject, func(t *testing.T) {
+			reason, err := fixupCommitFilter(context.Background(), &commit{Message: Message{Subject: tt.subject}})
+			require.NoError(t, err)
+			require.Equal(t, tt.reason, reason)
+		})
+	}
+}
+
+func TestSubjectFilter(t *testing.T) {
+	filter := subjectFilter([]*regexp.Regexp{
+		regexp.MustCompile(`^wip\b`),
+		regexp.MustCompile(`(?i)\[skip review\]`),
+	})
+
+	tests := []struct {
+		subject string
+		reason  string
+	}{
+		{subject: "wip parser", reason: `subject ^wip\b`},
+		{subject: "docs [Skip Review]", reason: `subject (?i)\[skip review\]`},
+		{subject: "wiped cache"},
+		{subject: "parser: add lexer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.subject, func(t *testing.T) {
+			reason, err := filter(context.Background(), &commit{Message: Message{Subject: tt.subject}})
+			require.NoError(t, err)
+			require.Equal(t, tt.reason, reason)
+		})
+	}
+}
+
+func TestMatchAnyPath(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		file     string
+		match    bool
+	}{
+		{patterns: []string{"*.md"}, file: "README.md", match: true},
+		{patterns: []string{"*.md"}, file: "docs/guide.md", match: true},
+		{patterns: []string{"docs/*"}, file: "docs/guide.md", match: true},
+		{patterns: []string{"docs/*"}, file: "docs/api/index.md"},
+		{patterns: []string{"*.md", "go.sum"}, file: "go.sum", match: true},
+		{patterns: []string{"*.md"}, file: "main.go"},
+		{patterns: []string{"[docs"}, file: "docs"},
+		{file: "README.md"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			require.Equal(t, tt.match, matchAnyPath(tt.patterns, tt.file))
+		})
+	}
+}
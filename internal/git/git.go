@@ -1,10 +1,9 @@
 package git
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os/exec"
 	"strings"
 	"time"
@@ -12,13 +11,24 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/waffleboot/giiter/internal/app"
+	"github.com/waffleboot/giiter/internal/gitlab"
+	"github.com/waffleboot/giiter/internal/output"
 )
 
+// Runner запускает git, в тестах его заменяет мок из mocks
 type Runner interface {
 	AllBranches(context.Context) ([]string, error)
 	ChangedFiles(_ context.Context, sha string) ([]string, error)
+	Run(_ context.Context, args ...string) ([]string, error)
+	RunTo(_ context.Context, w io.Writer, args ...string) error
 }
 
+// gitRunner через него идут все команды git пакета
+var gitRunner Runner = runner{}
+
+// pushDelay пауза перед командами, которые меняют ветки на сервере
+var pushDelay = 500 * time.Millisecond
+
 func AllBranches(ctx context.Context, runner Runner) ([]Branch, error) {
 	output, err := runner.AllBranches(ctx)
 	if err != nil {
@@ -49,7 +59,7 @@ func DeleteBranch(ctx context.Context, branchName string) error {
 		return err
 	}
 
-	<-time.After(500 * time.Millisecond)
+	<-time.After(pushDelay)
 
 	_, err = run(ctx, "push", "origin", "--delete", branchName)
 
@@ -66,24 +76,61 @@ func CreateBranch(ctx context.Context, branch Branch) error {
 	return err
 }
 
+// MergeRequestTitle возвращает заголовок нового MR для коммита
+func MergeRequestTitle(subject string) string {
+	title := subject
+	if app.Config.MergeRequestPrefix != "" {
+		title = app.Config.MergeRequestPrefix + ": " + title
+	}
+
+	return gitlab.DraftTitle(title, app.Config.MergeRequestDraft)
+}
+
 type MergeRequest struct {
 	Title        string
 	SourceBranch string
 	TargetBranch string
 	Description  string
+	Options      app.MergeRequestOptions
 }
 
+// CreateMergeRequest отправляет review ветку и создает MR через push options или через API при mr_api
 func CreateMergeRequest(ctx context.Context, req MergeRequest) error {
 	if isProtectedBranch(req.SourceBranch) {
 		return fmt.Errorf("%s is protected branch, merge requests disabled", req.SourceBranch)
 	}
 
+	if app.Config.MergeRequestAPI {
+		return createMergeRequestByAPI(ctx, req)
+	}
+
 	args := []string{
 		"push",
 		"-o", "merge_request.create",
 		"-o", "merge_request.target=" + req.TargetBranch,
 		"-o", "merge_request.title=" + req.Title,
-		"-o", "merge_request.label=review",
+	}
+
+	for _, label := range req.Options.Labels {
+		args = append(args, "-o", "merge_request.label="+label)
+	}
+
+	for _, assignee := range req.Options.Assignees {
+		if isEmail(assignee) {
+			output.Warnf("skip assignee %s, push options need a username, use mr_api to find users by email", assignee)
+
+			continue
+		}
+
+		args = append(args, "-o", "merge_request.assign="+assignee)
+	}
+
+	if req.Options.Milestone != "" {
+		args = append(args, "-o", "merge_request.milestone="+req.Options.Milestone)
+	}
+
+	if app.Enabled(req.Options.RemoveSourceBranch) {
+		args = append(args, "-o", "merge_request.remove_source_branch")
 	}
 
 	if req.Description != "" {
@@ -92,15 +139,24 @@ func CreateMergeRequest(ctx context.Context, req MergeRequest) error {
 
 	args = append(args, "origin", req.SourceBranch+":"+req.SourceBranch)
 
-	<-time.After(500 * time.Millisecond)
+	<-time.After(pushDelay)
+
+	if _, err := run(ctx, args...); err != nil {
+		return err
+	}
 
-	_, err := run(ctx, args...)
+	// ревьюеров и squash push options не поддерживают, MR уже создан, поэтому ошибка только выводится
+	if app.Config.EnableGitPush && (len(req.Options.Reviewers) > 0 || app.Enabled(req.Options.Squash)) {
+		if err := updateReview(ctx, req); err != nil {
+			output.Warnf("could not set reviewers and squash of %s: %v", req.SourceBranch, err)
+		}
+	}
 
-	return err
+	return nil
 }
 
 func validateBranches(ctx context.Context, baseBranch, featureBranch string) error {
-	branches, err := AllBranches(ctx, runner{})
+	branches, err := AllBranches(ctx, gitRunner)
 	if err != nil {
 		return errors.WithMessage(err, "get all branches")
 	}
@@ -145,40 +201,31 @@ func findCommitsBetween(ctx context.Context, baseBranch, featureBranch string) (
 	return commits, nil
 }
 
-func isEmptyCommit(ctx context.Context, commit string) (bool, error) {
-	files, err := changedFiles(ctx, commit, runner{})
+func commitParents(ctx context.Context, sha string) ([]string, error) {
+	output, err := run(ctx, "rev-list", "--parents", "-n", "1", sha)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	return len(files) == 0, nil
-}
-
-func filterCommit(ctx context.Context, commit string) (bool, error) {
-	emptyCommit, err := isEmptyCommit(ctx, commit)
-	if err != nil {
-		return false, err
+	if len(output) == 0 {
+		return nil, errors.Errorf("commit %s not found", sha)
 	}
 
-	return emptyCommit, nil
+	return strings.Fields(output[0])[1:], nil
 }
 
-func filterCommits(ctx context.Context, commits []string) ([]string, error) {
-	var j int
-
-	for i := range commits {
-		skip, err := filterCommit(ctx, commits[i])
-		if err != nil {
-			return nil, err
-		}
+func isAncestor(ctx context.Context, ancestor, descendant string) (bool, error) {
+	_, err := run(ctx, "merge-base", "--is-ancestor", ancestor, descendant)
+	if err == nil {
+		return true, nil
+	}
 
-		if !skip {
-			commits[j] = commits[i]
-			j++
-		}
+	var errExit *exec.ExitError
+	if errors.As(err, &errExit) && errExit.ExitCode() == 1 {
+		return false, nil
 	}
 
-	return commits[:j], nil
+	return false, err
 }
 
 func reverseCommits(commits []string) []string {
@@ -191,7 +238,8 @@ func reverseCommits(commits []string) []string {
 	return commits
 }
 
-func Commits(ctx context.Context, baseBranch, featureBranch string) ([]string, error) {
+// allCommits возвращает все коммиты feature ветки от старых к новым, без фильтрации
+func allCommits(ctx context.Context, baseBranch, featureBranch string) ([]string, error) {
 	if err := validateBranches(ctx, baseBranch, featureBranch); err != nil {
 		return nil, err
 	}
@@ -201,46 +249,89 @@ func Commits(ctx context.Context, baseBranch, featureBranch string) ([]string, e
 		return nil, err
 	}
 
-	commits, err = filterCommits(ctx, commits)
-	if err != nil {
-		return nil, err
-	}
-
-	return reverseCommits(commits), err
+	return reverseCommits(commits), nil
 }
 
 func SwitchBranch(ctx context.Context, branch, commit string) error {
+	return switchBranch(ctx, branch, commit, "")
+}
+
+// switchBranch переключает review ветку на коммит, непустой target меняет целевую ветку MR
+func switchBranch(ctx context.Context, branch, commit, target string) error {
 	if isProtectedBranch(branch) {
 		return fmt.Errorf("%s is protected branch, disable switch", branch)
 	}
 
-	_, err := run(ctx, "branch", "-f", branch, commit)
+	prevReviewSHA, err := branchSHA(ctx, branch)
+	if err != nil {
+		return err
+	}
+
+	_, err = run(ctx, "branch", "-f", branch, commit)
 	if err != nil {
 		return err
 	}
 
-	<-time.After(500 * time.Millisecond)
+	<-time.After(pushDelay)
 
-	_, err = run(ctx, "push", "origin", "--force", branch+":"+branch)
+	args := []string{"push", "origin", "--force"}
+	if target != "" {
+		args = append(args, "-o", "merge_request.target="+target)
+	}
 
-	return err
+	_, err = run(ctx, append(args, branch+":"+branch)...)
+	if err != nil {
+		return err
+	}
+
+	// GitLab не применяет push options, если ветка на сервере не изменилась, тогда MR перецепляется через API
+	if target != "" && prevReviewSHA == commit && app.Config.EnableGitPush {
+		if err := retargetMergeRequest(ctx, branch, target); err != nil {
+			output.Warnf("could not change target of %s to %s: %v", branch, target, err)
+		}
+	}
+
+	// ветка уже отправлена, поэтому ошибка заметки не должна прерывать команду
+	if app.Config.MergeRequestNotes && app.Config.EnableGitPush && prevReviewSHA != "" {
+		if err := postInterdiffNote(ctx, branch, prevReviewSHA, commit); err != nil {
+			output.Warnf("could not post changes to MR of %s: %v", branch, err)
+		}
+	}
+
+	return nil
+}
+
+// branchSHA возвращает коммит ветки, пустой если ветки нет
+func branchSHA(ctx context.Context, branch string) (string, error) {
+	exists, err := branchExists(ctx, branch)
+	if err != nil || !exists {
+		return "", err
+	}
+
+	output, err := run(ctx, "rev-parse", "--short", "refs/heads/"+branch)
+	if err != nil {
+		return "", err
+	}
+
+	return output[0], nil
 }
 
 func findCommit(ctx context.Context, sha string) (*commit, error) {
-	output, err := run(ctx, "log", "--pretty=format:%s%n%b", sha, "-1")
+	output, err := run(ctx, "log", "--pretty=format:%p%n%s%n%b", sha, "-1")
 	if err != nil {
 		return nil, err
 	}
 
 	var body string
-	if len(output) > 1 {
-		body = strings.Join(output[1:], "\n")
+	if len(output) > 2 {
+		body = strings.Join(output[2:], "\n")
 	}
 
 	commit := &commit{
-		SHA: sha,
+		SHA:     sha,
+		Parents: strings.Fields(output[0]),
 		Message: Message{
-			Subject:     output[0],
+			Subject:     output[1],
 			Description: body,
 		},
 	}
@@ -249,7 +340,12 @@ func findCommit(ctx context.Context, sha string) (*commit, error) {
 }
 
 func Rebase(ctx context.Context, baseBranch, featureBranch string) error {
-	fmt.Printf("git rebase --onto %s %s %s\n", baseBranch, baseBranch, featureBranch)
+	head, err := revParse(ctx, featureBranch)
+	if err != nil {
+		return err
+	}
+
+	output.Infof("git rebase --onto %s %s %s\n", baseBranch, baseBranch, featureBranch)
 
 	_, errRebase := run(ctx, "rebase", "--onto", baseBranch, baseBranch, featureBranch)
 	if errRebase != nil {
@@ -266,7 +362,34 @@ func Rebase(ctx context.Context, baseBranch, featureBranch string) error {
 		return errRebase
 	}
 
-	return nil
+	return restackChildren(ctx, featureBranch, head)
+}
+
+// StartRebase перестраивает feature ветку на base ветку как Rebase,
+// но при конфликте оставляет rebase незавершенным для giiter continue или giiter abort
+func StartRebase(ctx context.Context, baseBranch, featureBranch string) error {
+	if err := checkNoPendingOperation(); err != nil {
+		return err
+	}
+
+	head, err := revParse(ctx, featureBranch)
+	if err != nil {
+		return err
+	}
+
+	op := &app.Operation{
+		BaseBranch:    baseBranch,
+		FeatureBranch: featureBranch,
+		Head:          head,
+	}
+
+	output.Infof("git rebase --onto %s %s %s\n", baseBranch, baseBranch, featureBranch)
+
+	if err := runRebase(ctx, op, "rebase", "--onto", baseBranch, baseBranch, featureBranch); err != nil {
+		return err
+	}
+
+	return restackChildren(ctx, featureBranch, head)
 }
 
 type ErrRun struct {
@@ -279,13 +402,17 @@ func (e ErrRun) Error() string {
 	return e.err.Error()
 }
 
+func (e ErrRun) Unwrap() error {
+	return e.err
+}
+
 func (e ErrRun) log() {
 	for i := range e.stdOutput {
-		fmt.Println(e.stdOutput[i])
+		output.Infof("%s\n", e.stdOutput[i])
 	}
 
 	for i := range e.errOutput {
-		fmt.Println(e.errOutput[i])
+		output.Infof("%s\n", e.errOutput[i])
 	}
 }
 
@@ -295,13 +422,7 @@ func run(ctx context.Context, args ...string) ([]string, error) {
 	}
 
 	if app.Config.Verbose {
-		fmt.Print("git")
-
-		for i := range args {
-			fmt.Printf(" %s", args[i])
-		}
-
-		fmt.Println()
+		output.Infof("git %s\n", strings.Join(args, " "))
 	}
 
 	// if app.Config.Log != nil {
@@ -313,52 +434,5 @@ func run(ctx context.Context, args ...string) ([]string, error) {
 	// 	fmt.Fprintln(app.Config.Log)
 	// }
 
-	cmd := exec.CommandContext(ctx, "git", args...)
-
-	// cmd.Dir = app.Config.Repo
-
-	stdOut := new(bytes.Buffer)
-	stdErr := new(bytes.Buffer)
-
-	cmd.Stdout = stdOut
-	cmd.Stderr = stdErr
-
-	errRun := cmd.Run()
-
-	stdOutLines, errParseStdOut := bytesBufferToSlice(stdOut)
-	stdErrLines, errParseErrOut := bytesBufferToSlice(stdErr)
-
-	if errParseStdOut != nil {
-		fmt.Println(errParseStdOut)
-	}
-
-	if errParseErrOut != nil {
-		fmt.Println(errParseErrOut)
-	}
-
-	if errRun != nil {
-		return nil, ErrRun{
-			stdOutput: stdOutLines,
-			errOutput: stdErrLines,
-			err:       errRun,
-		}
-	}
-
-	return stdOutLines, nil
-}
-
-func bytesBufferToSlice(buf *bytes.Buffer) ([]string, error) {
-	var output []string
-
-	scanner := bufio.NewScanner(buf)
-
-	for scanner.Scan() {
-		output = append(output, scanner.Text())
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
-
-	return output, nil
+	return gitRunner.Run(ctx, args...)
 }
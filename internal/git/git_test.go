@@ -40,3 +40,132 @@ func TestChangedFiles(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, []string{"русский"}, files)
 }
+
+func TestMessageTrailers(t *testing.T) {
+	msg := Message{
+		Subject:     "subject",
+		Description: "Review-Group: body\n\nSigned-off-by: a\nreview-group: parser\nLabel: x\n",
+	}
+	require.Equal(t, "parser", msg.Trailer(GroupTrailer))
+	require.Equal(t, []string{"x"}, msg.Trailers("Label"))
+	require.Empty(t, Message{Subject: "subject"}.Trailer(GroupTrailer))
+}
+
+func TestCommitParents(t *testing.T) {
+	f := newFakeRepo(t)
+	f.commits["abcdef0"] = fakeCommit{subject: "base"}
+	f.add("abcdef0", "1111111 lexer")
+	f.add("abcdef0", "7777777 topic")
+	f.commits["8888888"] = fakeCommit{parents: []string{"1111111", "7777777"}, subject: "merge topic"}
+	f.on("rev-list --parents -n 1 5555555")
+
+	tests := []struct {
+		sha     string
+		parents []string
+		err     string
+	}{
+		{sha: "abcdef0", parents: []string{}},
+		{sha: "1111111", parents: []string{"abcdef0"}},
+		{sha: "8888888", parents: []string{"1111111", "7777777"}},
+		{sha: "5555555", err: "commit 5555555 not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sha, func(t *testing.T) {
+			parents, err := commitParents(context.Background(), tt.sha)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.parents, parents)
+		})
+	}
+}
+
+func TestMergeMarker(t *testing.T) {
+	f := newFakeRepo(t)
+	f.commits["abcdef0"] = fakeCommit{subject: "base", files: []string{"base.go"}}
+	f.add("abcdef0", "1111111 lexer")
+	f.add("abcdef0", "7777777 topic")
+	f.add("abcdef0", "bbbbbbb master fix")
+	f.commits["8888888"] = fakeCommit{parents: []string{"1111111", "7777777"}, subject: "merge topic", files: []string{"topic.go"}}
+	f.commits["9999999"] = fakeCommit{parents: []string{"8888888", "bbbbbbb"}, subject: "merge master", files: []string{"fix.go"}}
+	f.branches = map[string]string{"master": "bbbbbbb", "fa": "9999999"}
+	f.fail("merge-base --is-ancestor 7777777 master", exitError(1))
+	f.on("merge-base --is-ancestor bbbbbbb master")
+
+	records, err := State(context.Background(), "master", "fa")
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+
+	require.False(t, records[0].IsMergeCommit())
+	require.True(t, records[1].IsMergeCommit())
+	require.False(t, records[1].IsSkipped())
+	require.True(t, records[2].IsSkipped())
+	require.Equal(t, "merge from master", records[2].SkipReason())
+
+	// merge внутри review группы отмечает всю группу
+	group := Message{Subject: "parser", Description: "Review-Group: parser"}
+	record := newRecord(&commit{SHA: "1111111", Parents: []string{"abcdef0"}, Message: group})
+	require.False(t, record.IsMergeCommit())
+	record.joinGroup(&commit{SHA: "8888888", Parents: []string{"1111111", "7777777"}, Message: group})
+	require.True(t, record.IsMergeCommit())
+
+	review := newReviewRecord(&commit{SHA: "8888888", Parents: []string{"1111111", "7777777"}},
+		newReviewBranch(1, Branch{CommitSHA: "8888888", BranchName: "review/fa/1"}))
+	require.True(t, review.IsMergeCommit())
+}
+
+func TestReviewDiffHashBound(t *testing.T) {
+	f := newFakeRepo(t)
+	f.commits["abcdef0"] = fakeCommit{subject: "base", files: []string{"base.go"}}
+	// группа parser уже влита в master, новая группа с тем же именем не должна ее захватить
+	f.commits["0000001"] = fakeCommit{parents: []string{"abcdef0"}, subject: "old parser", body: "Review-Group: parser"}
+	f.commits["5000000"] = fakeCommit{parents: []string{"0000001"}, subject: "lexer", body: "Review-Group: parser"}
+	f.commits["6000000"] = fakeCommit{parents: []string{"5000000"}, subject: "ast", body: "Review-Group: parser"}
+	f.branches = map[string]string{"master": "0000001"}
+
+	review, err := findCommit(context.Background(), "6000000")
+	require.NoError(t, err)
+
+	_, base, err := reviewDiffHash(context.Background(), review, "master")
+	require.NoError(t, err)
+	require.Equal(t, "0000001", base)
+
+	_, base, err = reviewDiffHash(context.Background(), review, "")
+	require.NoError(t, err)
+	require.Equal(t, "abcdef0", base)
+}
+
+func TestGroupDescription(t *testing.T) {
+	record := newRecord(&commit{SHA: "1111111", Message: Message{
+		Subject:     "parser: add lexer",
+		Description: "Lexer splits input\ninto tokens.\n\nReview-Group: parser\nReviewer: bob\n",
+	}})
+	record.joinGroup(&commit{SHA: "2222222", Message: Message{
+		Subject:     "parser: add ast",
+		Description: "Review-Group: parser\nLabel: parser",
+	}})
+	record.joinGroup(&commit{SHA: "3333333", Message: Message{
+		Subject:     "parser: docs",
+		Description: "Note: this line is not a trailer paragraph\nbecause this one is not a trailer.",
+	}})
+
+	msg := record.CommitMessage()
+	require.Equal(t, "parser: add lexer", msg.Subject)
+	require.Equal(t, "* parser: add lexer\n\n"+
+		"  Lexer splits input\n"+
+		"  into tokens.\n\n"+
+		"* parser: add ast\n"+
+		"* parser: docs\n\n"+
+		"  Note: this line is not a trailer paragraph\n"+
+		"  because this one is not a trailer.\n\n"+
+		"Review-Group: parser\n"+
+		"Reviewer: bob\n"+
+		"Label: parser", msg.Description)
+	require.Equal(t, []string{"bob"}, msg.Trailers("Reviewer"))
+	require.Equal(t, "parser", msg.Trailer(GroupTrailer))
+}
@@ -0,0 +1,295 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/waffleboot/giiter/internal/app"
+	"github.com/waffleboot/giiter/internal/gitlab"
+	"github.com/waffleboot/giiter/internal/output"
+)
+
+// gitlabClient создает клиент GitLab API, адрес API и проект по умолчанию берутся из origin
+func gitlabClient(ctx context.Context) (*gitlab.Client, error) {
+	settings := app.Config.GitLab
+	if settings.Token == "" {
+		return nil, errors.New("gitlab.token is not set, use GIITER_GITLAB_TOKEN or giiter config set --user gitlab.token")
+	}
+
+	apiURL, project := settings.URL, settings.Project
+
+	if apiURL == "" || project == "" {
+		web, err := WebURL(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		u, err := url.Parse(web)
+		if err != nil {
+			return nil, err
+		}
+
+		if apiURL == "" {
+			apiURL = u.Scheme + "://" + u.Host + "/api/v4"
+		}
+
+		if project == "" {
+			project = strings.Trim(u.Path, "/")
+		}
+	}
+
+	return gitlab.NewClient(apiURL, project, settings.Token), nil
+}
+
+// SetDraft отмечает открытый MR review ветки черновиком или готовым к ревью,
+// changed false если MR уже в нужном состоянии
+func SetDraft(ctx context.Context, branch string, draft bool) (changed bool, err error) {
+	client, err := gitlabClient(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	mr, err := client.FindMergeRequest(ctx, branch)
+	if err != nil {
+		return false, err
+	}
+
+	if mr == nil {
+		return false, fmt.Errorf("%s has no open merge request", branch)
+	}
+
+	if gitlab.IsDraft(mr.Title) == draft {
+		return false, nil
+	}
+
+	return true, client.UpdateTitle(ctx, mr.IID, gitlab.DraftTitle(mr.Title, draft))
+}
+
+// retargetMergeRequest меняет целевую ветку открытого MR review ветки, если она другая
+func retargetMergeRequest(ctx context.Context, branch, target string) error {
+	client, err := gitlabClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	mr, err := client.FindMergeRequest(ctx, branch)
+	if err != nil {
+		return err
+	}
+
+	if mr == nil {
+		return fmt.Errorf("%s has no open merge request", branch)
+	}
+
+	if mr.TargetBranch == target {
+		return nil
+	}
+
+	return client.UpdateTarget(ctx, mr.IID, target)
+}
+
+// MergeRequestOptions собирает метаданные MR записи: настройки репозитория, настройки feature ветки,
+// флаги make и трейлеры коммита Label, Assignee, Reviewer, Reviewed-by и Milestone
+func MergeRequestOptions(featureBranch string, flags app.MergeRequestOptions, msg Message) app.MergeRequestOptions {
+	repo := app.Config.Shared.MergeRequests
+
+	// метка review была единственной до настроек MR, она остается, пока метки репозитория не заданы
+	if repo.Labels == nil {
+		repo.Labels = []string{"review"}
+	}
+
+	trailers := app.MergeRequestOptions{
+		Labels:    msg.Trailers("Label"),
+		Assignees: usernames(msg.Trailers("Assignee")),
+		Reviewers: usernames(append(msg.Trailers("Reviewer"), msg.Trailers("Reviewed-by")...)),
+		Milestone: msg.Trailer("Milestone"),
+	}
+
+	var options app.MergeRequestOptions
+
+	for _, layer := range []app.MergeRequestOptions{repo.MergeRequestOptions, repo.Features[featureBranch], flags, trailers} {
+		options.Labels = appendUnique(options.Labels, layer.Labels...)
+		options.Assignees = appendUnique(options.Assignees, layer.Assignees...)
+		options.Reviewers = appendUnique(options.Reviewers, layer.Reviewers...)
+
+		if layer.Milestone != "" {
+			options.Milestone = layer.Milestone
+		}
+
+		// флаг следующего слоя заменяет прежний, так make --squash=false выключает squash из .giiter.yml
+		if layer.RemoveSourceBranch != nil {
+			options.RemoveSourceBranch = layer.RemoveSourceBranch
+		}
+
+		if layer.Squash != nil {
+			options.Squash = layer.Squash
+		}
+	}
+
+	return options
+}
+
+// usernames оставляет от значений трейлеров пользователей GitLab: @alice дает alice,
+// а Carol Smith <carol@example.com> дает адрес carol@example.com, по нему пользователь ищется через API,
+// имя без адреса с пробелами не может быть пользователем GitLab и пропускается
+func usernames(values []string) []string {
+	names := make([]string, 0, len(values))
+
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+
+		if i := strings.Index(value, "<"); i >= 0 {
+			if j := strings.Index(value[i:], ">"); j > 0 {
+				value = value[i+1 : i+j]
+			}
+		}
+
+		value = strings.TrimPrefix(value, "@")
+
+		switch {
+		case value == "":
+			continue
+		case strings.ContainsAny(value, " \t"):
+			output.Warnf("skip %q, it is not a GitLab username or email", value)
+
+			continue
+		}
+
+		names = append(names, value)
+	}
+
+	return names
+}
+
+// isEmail отличает адрес из трейлера Reviewed-by от имени пользователя
+func isEmail(name string) bool {
+	return strings.Contains(name, "@")
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		found := false
+
+		for _, item := range list {
+			if item == value {
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			list = append(list, value)
+		}
+	}
+
+	return list
+}
+
+// createMergeRequestByAPI отправляет review ветку обычным push и создает MR через API со всеми метаданными.
+// Пользователи и milestone ищутся до push, чтобы ошибка в них не оставила отправленную ветку без MR
+func createMergeRequestByAPI(ctx context.Context, req MergeRequest) error {
+	// push отключен, значит и MR создавать не для чего
+	if !app.Config.EnableGitPush {
+		return nil
+	}
+
+	client, err := gitlabClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	mr := gitlab.MergeRequest{
+		Title:              req.Title,
+		SourceBranch:       req.SourceBranch,
+		TargetBranch:       req.TargetBranch,
+		Description:        req.Description,
+		Labels:             req.Options.Labels,
+		RemoveSourceBranch: app.Enabled(req.Options.RemoveSourceBranch),
+		Squash:             app.Enabled(req.Options.Squash),
+	}
+
+	if mr.AssigneeIDs, err = userIDs(ctx, client, req.Options.Assignees); err != nil {
+		return err
+	}
+
+	if mr.ReviewerIDs, err = userIDs(ctx, client, req.Options.Reviewers); err != nil {
+		return err
+	}
+
+	if req.Options.Milestone != "" {
+		if mr.MilestoneID, err = client.MilestoneID(ctx, req.Options.Milestone); err != nil {
+			return err
+		}
+	}
+
+	<-time.After(pushDelay)
+
+	if _, err := run(ctx, "push", "origin", req.SourceBranch+":"+req.SourceBranch); err != nil {
+		return err
+	}
+
+	return client.CreateMergeRequest(ctx, mr)
+}
+
+// updateReview назначает ревьюеров и squash MR, созданному через push options
+func updateReview(ctx context.Context, req MergeRequest) error {
+	client, err := gitlabClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	mr, err := client.FindMergeRequest(ctx, req.SourceBranch)
+	if err != nil {
+		return err
+	}
+
+	if mr == nil {
+		return fmt.Errorf("%s has no open merge request", req.SourceBranch)
+	}
+
+	reviewerIDs, err := userIDs(ctx, client, req.Options.Reviewers)
+	if err != nil {
+		return err
+	}
+
+	return client.UpdateReview(ctx, mr.IID, reviewerIDs, app.Enabled(req.Options.Squash))
+}
+
+// userIDs ищет пользователей по именам и адресам, адрес может не найтись, если он скрыт в профиле,
+// тогда пользователь пропускается, чтобы трейлер Reviewed-by не мешал создать MR
+func userIDs(ctx context.Context, client *gitlab.Client, names []string) ([]int, error) {
+	ids := make([]int, 0, len(names))
+
+	for _, name := range names {
+		if !isEmail(name) {
+			id, err := client.UserID(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+
+			ids = append(ids, id)
+
+			continue
+		}
+
+		id, err := client.UserIDByEmail(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if id == 0 {
+			output.Warnf("skip %s, no GitLab user with this public email", name)
+
+			continue
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,78 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/waffleboot/giiter/internal/app"
+)
+
+func TestMergeRequestOptions(t *testing.T) {
+	saved := app.Config.Shared.MergeRequests
+	defer func() { app.Config.Shared.MergeRequests = saved }()
+
+	yes, no := true, false
+
+	msg := Message{
+		Subject: "parser: add lexer",
+		Description: "Lexer for the parser.\n\n" +
+			"Label: parser\n" +
+			"Reviewed-by: @carol\n" +
+			"Reviewer: dave <dave@example.com>\n" +
+			"Reviewed-by: Erin Doe\n" +
+			"Milestone: v2",
+	}
+
+	t.Run("default label", func(t *testing.T) {
+		app.Config.Shared.MergeRequests = app.MergeRequestSettings{}
+
+		options := MergeRequestOptions("fa", app.MergeRequestOptions{}, Message{Subject: "docs"})
+		require.Equal(t, app.MergeRequestOptions{Labels: []string{"review"}}, options)
+	})
+
+	t.Run("layers", func(t *testing.T) {
+		app.Config.Shared.MergeRequests = app.MergeRequestSettings{
+			MergeRequestOptions: app.MergeRequestOptions{
+				Labels:    []string{"backend"},
+				Milestone: "v1",
+			},
+			Features: map[string]app.MergeRequestOptions{
+				"fa": {Reviewers: []string{"bob", "carol"}, Squash: &yes},
+				"fb": {Labels: []string{"frontend"}},
+			},
+		}
+
+		flags := app.MergeRequestOptions{
+			Labels:             []string{"backend", "urgent"},
+			Assignees:          []string{"alice"},
+			RemoveSourceBranch: &yes,
+		}
+
+		options := MergeRequestOptions("fa", flags, msg)
+		require.Equal(t, app.MergeRequestOptions{
+			Labels:             []string{"backend", "urgent", "parser"},
+			Assignees:          []string{"alice"},
+			Reviewers:          []string{"bob", "carol", "dave@example.com"},
+			Milestone:          "v2",
+			RemoveSourceBranch: &yes,
+			Squash:             &yes,
+		}, options)
+	})
+
+	t.Run("flags override", func(t *testing.T) {
+		app.Config.Shared.MergeRequests = app.MergeRequestSettings{
+			MergeRequestOptions: app.MergeRequestOptions{RemoveSourceBranch: &yes, Squash: &yes},
+		}
+
+		options := MergeRequestOptions("fa", app.MergeRequestOptions{Squash: &no}, Message{Subject: "docs"})
+		require.False(t, app.Enabled(options.Squash))
+		require.True(t, app.Enabled(options.RemoveSourceBranch))
+	})
+}
+
+func TestUsernames(t *testing.T) {
+	require.Equal(t,
+		[]string{"alice", "bob", "carol@example.com"},
+		usernames([]string{"@alice", " bob ", "Carol Smith <carol@example.com>", "Erin Doe", ""}))
+}
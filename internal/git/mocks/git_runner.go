@@ -6,6 +6,7 @@ package mocks
 
 import (
 	"context"
+	"io"
 	"sync"
 	mm_atomic "sync/atomic"
 	mm_time "time"
@@ -28,6 +29,18 @@ type GitRunnerMock struct {
 	afterChangedFilesCounter  uint64
 	beforeChangedFilesCounter uint64
 	ChangedFilesMock          mGitRunnerMockChangedFiles
+
+	funcRun          func(ctx context.Context, args ...string) (sa1 []string, err error)
+	inspectFuncRun   func(ctx context.Context, args ...string)
+	afterRunCounter  uint64
+	beforeRunCounter uint64
+	RunMock          mGitRunnerMockRun
+
+	funcRunTo          func(ctx context.Context, w io.Writer, args ...string) (err error)
+	inspectFuncRunTo   func(ctx context.Context, w io.Writer, args ...string)
+	afterRunToCounter  uint64
+	beforeRunToCounter uint64
+	RunToMock          mGitRunnerMockRunTo
 }
 
 // NewGitRunnerMock returns a mock for git.GitRunner
@@ -43,6 +56,12 @@ func NewGitRunnerMock(t minimock.Tester) *GitRunnerMock {
 	m.ChangedFilesMock = mGitRunnerMockChangedFiles{mock: m}
 	m.ChangedFilesMock.callArgs = []*GitRunnerMockChangedFilesParams{}
 
+	m.RunMock = mGitRunnerMockRun{mock: m}
+	m.RunMock.callArgs = []*GitRunnerMockRunParams{}
+
+	m.RunToMock = mGitRunnerMockRunTo{mock: m}
+	m.RunToMock.callArgs = []*GitRunnerMockRunToParams{}
+
 	return m
 }
 
@@ -479,12 +498,450 @@ func (m *GitRunnerMock) MinimockChangedFilesInspect() {
 	}
 }
 
+type mGitRunnerMockRun struct {
+	mock               *GitRunnerMock
+	defaultExpectation *GitRunnerMockRunExpectation
+	expectations       []*GitRunnerMockRunExpectation
+
+	callArgs []*GitRunnerMockRunParams
+	mutex    sync.RWMutex
+}
+
+// GitRunnerMockRunExpectation specifies expectation struct of the GitRunner.Run
+type GitRunnerMockRunExpectation struct {
+	mock    *GitRunnerMock
+	params  *GitRunnerMockRunParams
+	results *GitRunnerMockRunResults
+	Counter uint64
+}
+
+// GitRunnerMockRunParams contains parameters of the GitRunner.Run
+type GitRunnerMockRunParams struct {
+	ctx  context.Context
+	args []string
+}
+
+// GitRunnerMockRunResults contains results of the GitRunner.Run
+type GitRunnerMockRunResults struct {
+	sa1 []string
+	err error
+}
+
+// Expect sets up expected params for GitRunner.Run
+func (mmRun *mGitRunnerMockRun) Expect(ctx context.Context, args ...string) *mGitRunnerMockRun {
+	if mmRun.mock.funcRun != nil {
+		mmRun.mock.t.Fatalf("GitRunnerMock.Run mock is already set by Set")
+	}
+
+	if mmRun.defaultExpectation == nil {
+		mmRun.defaultExpectation = &GitRunnerMockRunExpectation{}
+	}
+
+	mmRun.defaultExpectation.params = &GitRunnerMockRunParams{ctx, args}
+	for _, e := range mmRun.expectations {
+		if minimock.Equal(e.params, mmRun.defaultExpectation.params) {
+			mmRun.mock.t.Fatalf("Expectation set by When has same params: %#v", *mmRun.defaultExpectation.params)
+		}
+	}
+
+	return mmRun
+}
+
+// Inspect accepts an inspector function that has same arguments as the GitRunner.Run
+func (mmRun *mGitRunnerMockRun) Inspect(f func(ctx context.Context, args ...string)) *mGitRunnerMockRun {
+	if mmRun.mock.inspectFuncRun != nil {
+		mmRun.mock.t.Fatalf("Inspect function is already set for GitRunnerMock.Run")
+	}
+
+	mmRun.mock.inspectFuncRun = f
+
+	return mmRun
+}
+
+// Return sets up results that will be returned by GitRunner.Run
+func (mmRun *mGitRunnerMockRun) Return(sa1 []string, err error) *GitRunnerMock {
+	if mmRun.mock.funcRun != nil {
+		mmRun.mock.t.Fatalf("GitRunnerMock.Run mock is already set by Set")
+	}
+
+	if mmRun.defaultExpectation == nil {
+		mmRun.defaultExpectation = &GitRunnerMockRunExpectation{mock: mmRun.mock}
+	}
+	mmRun.defaultExpectation.results = &GitRunnerMockRunResults{sa1, err}
+	return mmRun.mock
+}
+
+//Set uses given function f to mock the GitRunner.Run method
+func (mmRun *mGitRunnerMockRun) Set(f func(ctx context.Context, args ...string) (sa1 []string, err error)) *GitRunnerMock {
+	if mmRun.defaultExpectation != nil {
+		mmRun.mock.t.Fatalf("Default expectation is already set for the GitRunner.Run method")
+	}
+
+	if len(mmRun.expectations) > 0 {
+		mmRun.mock.t.Fatalf("Some expectations are already set for the GitRunner.Run method")
+	}
+
+	mmRun.mock.funcRun = f
+	return mmRun.mock
+}
+
+// When sets expectation for the GitRunner.Run which will trigger the result defined by the following
+// Then helper
+func (mmRun *mGitRunnerMockRun) When(ctx context.Context, args ...string) *GitRunnerMockRunExpectation {
+	if mmRun.mock.funcRun != nil {
+		mmRun.mock.t.Fatalf("GitRunnerMock.Run mock is already set by Set")
+	}
+
+	expectation := &GitRunnerMockRunExpectation{
+		mock:   mmRun.mock,
+		params: &GitRunnerMockRunParams{ctx, args},
+	}
+	mmRun.expectations = append(mmRun.expectations, expectation)
+	return expectation
+}
+
+// Then sets up GitRunner.Run return parameters for the expectation previously defined by the When method
+func (e *GitRunnerMockRunExpectation) Then(sa1 []string, err error) *GitRunnerMock {
+	e.results = &GitRunnerMockRunResults{sa1, err}
+	return e.mock
+}
+
+// Run implements git.GitRunner
+func (mmRun *GitRunnerMock) Run(ctx context.Context, args ...string) (sa1 []string, err error) {
+	mm_atomic.AddUint64(&mmRun.beforeRunCounter, 1)
+	defer mm_atomic.AddUint64(&mmRun.afterRunCounter, 1)
+
+	if mmRun.inspectFuncRun != nil {
+		mmRun.inspectFuncRun(ctx, args...)
+	}
+
+	mm_params := &GitRunnerMockRunParams{ctx, args}
+
+	// Record call args
+	mmRun.RunMock.mutex.Lock()
+	mmRun.RunMock.callArgs = append(mmRun.RunMock.callArgs, mm_params)
+	mmRun.RunMock.mutex.Unlock()
+
+	for _, e := range mmRun.RunMock.expectations {
+		if minimock.Equal(e.params, mm_params) {
+			mm_atomic.AddUint64(&e.Counter, 1)
+			return e.results.sa1, e.results.err
+		}
+	}
+
+	if mmRun.RunMock.defaultExpectation != nil {
+		mm_atomic.AddUint64(&mmRun.RunMock.defaultExpectation.Counter, 1)
+		mm_want := mmRun.RunMock.defaultExpectation.params
+		mm_got := GitRunnerMockRunParams{ctx, args}
+		if mm_want != nil && !minimock.Equal(*mm_want, mm_got) {
+			mmRun.t.Errorf("GitRunnerMock.Run got unexpected parameters, want: %#v, got: %#v%s\n", *mm_want, mm_got, minimock.Diff(*mm_want, mm_got))
+		}
+
+		mm_results := mmRun.RunMock.defaultExpectation.results
+		if mm_results == nil {
+			mmRun.t.Fatal("No results are set for the GitRunnerMock.Run")
+		}
+		return (*mm_results).sa1, (*mm_results).err
+	}
+	if mmRun.funcRun != nil {
+		return mmRun.funcRun(ctx, args...)
+	}
+	mmRun.t.Fatalf("Unexpected call to GitRunnerMock.Run. %v %v", ctx, args)
+	return
+}
+
+// RunAfterCounter returns a count of finished GitRunnerMock.Run invocations
+func (mmRun *GitRunnerMock) RunAfterCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmRun.afterRunCounter)
+}
+
+// RunBeforeCounter returns a count of GitRunnerMock.Run invocations
+func (mmRun *GitRunnerMock) RunBeforeCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmRun.beforeRunCounter)
+}
+
+// Calls returns a list of arguments used in each call to GitRunnerMock.Run.
+// The list is in the same order as the calls were made (i.e. recent calls have a higher index)
+func (mmRun *mGitRunnerMockRun) Calls() []*GitRunnerMockRunParams {
+	mmRun.mutex.RLock()
+
+	argCopy := make([]*GitRunnerMockRunParams, len(mmRun.callArgs))
+	copy(argCopy, mmRun.callArgs)
+
+	mmRun.mutex.RUnlock()
+
+	return argCopy
+}
+
+// MinimockRunDone returns true if the count of the Run invocations corresponds
+// the number of defined expectations
+func (m *GitRunnerMock) MinimockRunDone() bool {
+	for _, e := range m.RunMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			return false
+		}
+	}
+
+	// if default expectation was set then invocations count should be greater than zero
+	if m.RunMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterRunCounter) < 1 {
+		return false
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcRun != nil && mm_atomic.LoadUint64(&m.afterRunCounter) < 1 {
+		return false
+	}
+	return true
+}
+
+// MinimockRunInspect logs each unmet expectation
+func (m *GitRunnerMock) MinimockRunInspect() {
+	for _, e := range m.RunMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			m.t.Errorf("Expected call to GitRunnerMock.Run with params: %#v", *e.params)
+		}
+	}
+
+	// if default expectation was set then invocations count should be greater than zero
+	if m.RunMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterRunCounter) < 1 {
+		if m.RunMock.defaultExpectation.params == nil {
+			m.t.Error("Expected call to GitRunnerMock.Run")
+		} else {
+			m.t.Errorf("Expected call to GitRunnerMock.Run with params: %#v", *m.RunMock.defaultExpectation.params)
+		}
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcRun != nil && mm_atomic.LoadUint64(&m.afterRunCounter) < 1 {
+		m.t.Error("Expected call to GitRunnerMock.Run")
+	}
+}
+
+type mGitRunnerMockRunTo struct {
+	mock               *GitRunnerMock
+	defaultExpectation *GitRunnerMockRunToExpectation
+	expectations       []*GitRunnerMockRunToExpectation
+
+	callArgs []*GitRunnerMockRunToParams
+	mutex    sync.RWMutex
+}
+
+// GitRunnerMockRunToExpectation specifies expectation struct of the GitRunner.RunTo
+type GitRunnerMockRunToExpectation struct {
+	mock    *GitRunnerMock
+	params  *GitRunnerMockRunToParams
+	results *GitRunnerMockRunToResults
+	Counter uint64
+}
+
+// GitRunnerMockRunToParams contains parameters of the GitRunner.RunTo
+type GitRunnerMockRunToParams struct {
+	ctx  context.Context
+	w    io.Writer
+	args []string
+}
+
+// GitRunnerMockRunToResults contains results of the GitRunner.RunTo
+type GitRunnerMockRunToResults struct {
+	err error
+}
+
+// Expect sets up expected params for GitRunner.RunTo
+func (mmRunTo *mGitRunnerMockRunTo) Expect(ctx context.Context, w io.Writer, args ...string) *mGitRunnerMockRunTo {
+	if mmRunTo.mock.funcRunTo != nil {
+		mmRunTo.mock.t.Fatalf("GitRunnerMock.RunTo mock is already set by Set")
+	}
+
+	if mmRunTo.defaultExpectation == nil {
+		mmRunTo.defaultExpectation = &GitRunnerMockRunToExpectation{}
+	}
+
+	mmRunTo.defaultExpectation.params = &GitRunnerMockRunToParams{ctx, w, args}
+	for _, e := range mmRunTo.expectations {
+		if minimock.Equal(e.params, mmRunTo.defaultExpectation.params) {
+			mmRunTo.mock.t.Fatalf("Expectation set by When has same params: %#v", *mmRunTo.defaultExpectation.params)
+		}
+	}
+
+	return mmRunTo
+}
+
+// Inspect accepts an inspector function that has same arguments as the GitRunner.RunTo
+func (mmRunTo *mGitRunnerMockRunTo) Inspect(f func(ctx context.Context, w io.Writer, args ...string)) *mGitRunnerMockRunTo {
+	if mmRunTo.mock.inspectFuncRunTo != nil {
+		mmRunTo.mock.t.Fatalf("Inspect function is already set for GitRunnerMock.RunTo")
+	}
+
+	mmRunTo.mock.inspectFuncRunTo = f
+
+	return mmRunTo
+}
+
+// Return sets up results that will be returned by GitRunner.RunTo
+func (mmRunTo *mGitRunnerMockRunTo) Return(err error) *GitRunnerMock {
+	if mmRunTo.mock.funcRunTo != nil {
+		mmRunTo.mock.t.Fatalf("GitRunnerMock.RunTo mock is already set by Set")
+	}
+
+	if mmRunTo.defaultExpectation == nil {
+		mmRunTo.defaultExpectation = &GitRunnerMockRunToExpectation{mock: mmRunTo.mock}
+	}
+	mmRunTo.defaultExpectation.results = &GitRunnerMockRunToResults{err}
+	return mmRunTo.mock
+}
+
+//Set uses given function f to mock the GitRunner.RunTo method
+func (mmRunTo *mGitRunnerMockRunTo) Set(f func(ctx context.Context, w io.Writer, args ...string) (err error)) *GitRunnerMock {
+	if mmRunTo.defaultExpectation != nil {
+		mmRunTo.mock.t.Fatalf("Default expectation is already set for the GitRunner.RunTo method")
+	}
+
+	if len(mmRunTo.expectations) > 0 {
+		mmRunTo.mock.t.Fatalf("Some expectations are already set for the GitRunner.RunTo method")
+	}
+
+	mmRunTo.mock.funcRunTo = f
+	return mmRunTo.mock
+}
+
+// When sets expectation for the GitRunner.RunTo which will trigger the result defined by the following
+// Then helper
+func (mmRunTo *mGitRunnerMockRunTo) When(ctx context.Context, w io.Writer, args ...string) *GitRunnerMockRunToExpectation {
+	if mmRunTo.mock.funcRunTo != nil {
+		mmRunTo.mock.t.Fatalf("GitRunnerMock.RunTo mock is already set by Set")
+	}
+
+	expectation := &GitRunnerMockRunToExpectation{
+		mock:   mmRunTo.mock,
+		params: &GitRunnerMockRunToParams{ctx, w, args},
+	}
+	mmRunTo.expectations = append(mmRunTo.expectations, expectation)
+	return expectation
+}
+
+// Then sets up GitRunner.RunTo return parameters for the expectation previously defined by the When method
+func (e *GitRunnerMockRunToExpectation) Then(err error) *GitRunnerMock {
+	e.results = &GitRunnerMockRunToResults{err}
+	return e.mock
+}
+
+// RunTo implements git.GitRunner
+func (mmRunTo *GitRunnerMock) RunTo(ctx context.Context, w io.Writer, args ...string) (err error) {
+	mm_atomic.AddUint64(&mmRunTo.beforeRunToCounter, 1)
+	defer mm_atomic.AddUint64(&mmRunTo.afterRunToCounter, 1)
+
+	if mmRunTo.inspectFuncRunTo != nil {
+		mmRunTo.inspectFuncRunTo(ctx, w, args...)
+	}
+
+	mm_params := &GitRunnerMockRunToParams{ctx, w, args}
+
+	// Record call args
+	mmRunTo.RunToMock.mutex.Lock()
+	mmRunTo.RunToMock.callArgs = append(mmRunTo.RunToMock.callArgs, mm_params)
+	mmRunTo.RunToMock.mutex.Unlock()
+
+	for _, e := range mmRunTo.RunToMock.expectations {
+		if minimock.Equal(e.params, mm_params) {
+			mm_atomic.AddUint64(&e.Counter, 1)
+			return e.results.err
+		}
+	}
+
+	if mmRunTo.RunToMock.defaultExpectation != nil {
+		mm_atomic.AddUint64(&mmRunTo.RunToMock.defaultExpectation.Counter, 1)
+		mm_want := mmRunTo.RunToMock.defaultExpectation.params
+		mm_got := GitRunnerMockRunToParams{ctx, w, args}
+		if mm_want != nil && !minimock.Equal(*mm_want, mm_got) {
+			mmRunTo.t.Errorf("GitRunnerMock.RunTo got unexpected parameters, want: %#v, got: %#v%s\n", *mm_want, mm_got, minimock.Diff(*mm_want, mm_got))
+		}
+
+		mm_results := mmRunTo.RunToMock.defaultExpectation.results
+		if mm_results == nil {
+			mmRunTo.t.Fatal("No results are set for the GitRunnerMock.RunTo")
+		}
+		return (*mm_results).err
+	}
+	if mmRunTo.funcRunTo != nil {
+		return mmRunTo.funcRunTo(ctx, w, args...)
+	}
+	mmRunTo.t.Fatalf("Unexpected call to GitRunnerMock.RunTo. %v %v %v", ctx, w, args)
+	return
+}
+
+// RunToAfterCounter returns a count of finished GitRunnerMock.RunTo invocations
+func (mmRunTo *GitRunnerMock) RunToAfterCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmRunTo.afterRunToCounter)
+}
+
+// RunToBeforeCounter returns a count of GitRunnerMock.RunTo invocations
+func (mmRunTo *GitRunnerMock) RunToBeforeCounter() uint64 {
+	return mm_atomic.LoadUint64(&mmRunTo.beforeRunToCounter)
+}
+
+// Calls returns a list of arguments used in each call to GitRunnerMock.RunTo.
+// The list is in the same order as the calls were made (i.e. recent calls have a higher index)
+func (mmRunTo *mGitRunnerMockRunTo) Calls() []*GitRunnerMockRunToParams {
+	mmRunTo.mutex.RLock()
+
+	argCopy := make([]*GitRunnerMockRunToParams, len(mmRunTo.callArgs))
+	copy(argCopy, mmRunTo.callArgs)
+
+	mmRunTo.mutex.RUnlock()
+
+	return argCopy
+}
+
+// MinimockRunToDone returns true if the count of the RunTo invocations corresponds
+// the number of defined expectations
+func (m *GitRunnerMock) MinimockRunToDone() bool {
+	for _, e := range m.RunToMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			return false
+		}
+	}
+
+	// if default expectation was set then invocations count should be greater than zero
+	if m.RunToMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterRunToCounter) < 1 {
+		return false
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcRunTo != nil && mm_atomic.LoadUint64(&m.afterRunToCounter) < 1 {
+		return false
+	}
+	return true
+}
+
+// MinimockRunToInspect logs each unmet expectation
+func (m *GitRunnerMock) MinimockRunToInspect() {
+	for _, e := range m.RunToMock.expectations {
+		if mm_atomic.LoadUint64(&e.Counter) < 1 {
+			m.t.Errorf("Expected call to GitRunnerMock.RunTo with params: %#v", *e.params)
+		}
+	}
+
+	// if default expectation was set then invocations count should be greater than zero
+	if m.RunToMock.defaultExpectation != nil && mm_atomic.LoadUint64(&m.afterRunToCounter) < 1 {
+		if m.RunToMock.defaultExpectation.params == nil {
+			m.t.Error("Expected call to GitRunnerMock.RunTo")
+		} else {
+			m.t.Errorf("Expected call to GitRunnerMock.RunTo with params: %#v", *m.RunToMock.defaultExpectation.params)
+		}
+	}
+	// if func was set then invocations count should be greater than zero
+	if m.funcRunTo != nil && mm_atomic.LoadUint64(&m.afterRunToCounter) < 1 {
+		m.t.Error("Expected call to GitRunnerMock.RunTo")
+	}
+}
+
 // MinimockFinish checks that all mocked methods have been called the expected number of times
 func (m *GitRunnerMock) MinimockFinish() {
 	if !m.minimockDone() {
 		m.MinimockAllBranchesInspect()
 
 		m.MinimockChangedFilesInspect()
+
+		m.MinimockRunInspect()
+
+		m.MinimockRunToInspect()
 		m.t.FailNow()
 	}
 }
@@ -509,5 +966,7 @@ func (m *GitRunnerMock) minimockDone() bool {
 	done := true
 	return done &&
 		m.MinimockAllBranchesDone() &&
-		m.MinimockChangedFilesDone()
+		m.MinimockChangedFilesDone() &&
+		m.MinimockRunDone() &&
+		m.MinimockRunToDone()
 }
@@ -2,8 +2,12 @@ package git
 
 import (
 	"errors"
+	"strings"
 )
 
+// GroupTrailer объединяет подряд идущие коммиты с одинаковым значением в одну review ветку
+const GroupTrailer = "Review-Group"
+
 type Message struct {
 	Subject     string
 	Description string
@@ -11,9 +15,14 @@ type Message struct {
 
 type commit struct {
 	SHA     string
+	Parents []string
 	Message Message
 }
 
+func (c *commit) isMerge() bool {
+	return len(c.Parents) > 1
+}
+
 type Branch struct {
 	CommitSHA  string
 	BranchName string
@@ -32,6 +41,49 @@ type Record struct {
 	reviewSHA      string
 	reviewMsg      Message
 	reviewBranches []reviewBranch
+	commits        []string
+	group          string
+	changeID       string
+	diffBase       string
+	skipReason     string
+	merge          bool
+	// groupMessages сообщения коммитов review группы, из них собирается описание MR группы
+	groupMessages []Message
+}
+
+// Статусы записи для машиночитаемого вывода
+const (
+	StatusNew     = "new"
+	StatusOld     = "old"
+	StatusMatched = "matched"
+	StatusSwitch  = "switch"
+	StatusSkipped = "skipped"
+)
+
+// Status возвращает статус записи
+func (r *Record) Status() string {
+	switch {
+	case r.IsSkipped():
+		return StatusSkipped
+	case r.IsNewCommit():
+		return StatusNew
+	case r.IsOldCommit():
+		return StatusOld
+	case r.MatchedCommit():
+		return StatusMatched
+	default:
+		return StatusSwitch
+	}
+}
+
+// FeatureSHA возвращает коммит feature ветки, пустой для старой записи
+func (r *Record) FeatureSHA() string {
+	return r.featureSHA
+}
+
+// ReviewSHA возвращает коммит review веток записи, пустой для новой записи
+func (r *Record) ReviewSHA() string {
+	return r.reviewSHA
 }
 
 func (r *Record) HasReview() bool {
@@ -39,7 +91,15 @@ func (r *Record) HasReview() bool {
 }
 
 func (r *Record) IsNewCommit() bool {
-	return r.reviewSHA == ""
+	return r.reviewSHA == "" && r.skipReason == ""
+}
+
+func (r *Record) IsSkipped() bool {
+	return r.skipReason != ""
+}
+
+func (r *Record) SkipReason() string {
+	return r.skipReason
 }
 
 func (r *Record) IsOldCommit() bool {
@@ -50,6 +110,32 @@ func (r *Record) MatchedCommit() bool {
 	return r.featureSHA == r.reviewSHA
 }
 
+func (r *Record) IsMergeCommit() bool {
+	return r.merge
+}
+
+func (r *Record) Group() string {
+	return r.group
+}
+
+func (r *Record) ChangeID() string {
+	return r.changeID
+}
+
+// Commits возвращает коммиты feature ветки из которых состоит запись, от старых к новым
+func (r *Record) Commits() []string {
+	return r.commits
+}
+
+// DiffBase возвращает коммит относительно которого считаются изменения записи
+func (r *Record) DiffBase() string {
+	if r.diffBase != "" {
+		return r.diffBase
+	}
+
+	return r.CommitSHA() + "~"
+}
+
 func (r *Record) addReviewBranch(branch reviewBranch) {
 	r.reviewSHA = branch.branch.CommitSHA
 	r.reviewBranches = append(r.reviewBranches, branch)
@@ -103,10 +189,45 @@ func (r *Record) switchBranch() {
 }
 
 func newRecord(commit *commit) Record {
-	return Record{
+	record := Record{
 		featureSHA: commit.SHA,
 		featureMsg: commit.Message,
+		commits:    []string{commit.SHA},
+		group:      commit.Message.Trailer(GroupTrailer),
+		changeID:   commit.Message.Trailer(ChangeIDTrailer),
+		merge:      commit.isMerge(),
 	}
+
+	if record.group != "" && len(commit.Parents) > 0 {
+		record.diffBase = commit.Parents[0]
+	}
+
+	return record
+}
+
+// joinGroup добавляет следующий коммит группы, запись указывает на последний коммит группы,
+// а изменения считаются от родителя первого коммита
+func (r *Record) joinGroup(commit *commit) {
+	if len(r.commits) == 1 {
+		r.groupMessages = []Message{r.featureMsg}
+	}
+
+	r.groupMessages = append(r.groupMessages, commit.Message)
+
+	r.featureSHA = commit.SHA
+	r.featureMsg = Message{
+		Subject:     r.groupMessages[0].Subject,
+		Description: groupDescription(r.groupMessages),
+	}
+	r.commits = append(r.commits, commit.SHA)
+	r.merge = r.merge || commit.isMerge()
+}
+
+func newSkippedRecord(commit *commit, reason string) Record {
+	record := newRecord(commit)
+	record.skipReason = reason
+
+	return record
 }
 
 func newReviewRecord(commit *commit, branch reviewBranch) Record {
@@ -114,6 +235,7 @@ func newReviewRecord(commit *commit, branch reviewBranch) Record {
 		reviewSHA:      commit.SHA,
 		reviewMsg:      commit.Message,
 		reviewBranches: []reviewBranch{branch},
+		merge:          commit.isMerge(),
 	}
 }
 
@@ -139,3 +261,96 @@ func (r *Record) MaxID() int {
 
 	return maxID
 }
+
+// groupDescription собирает описание MR группы: список коммитов с их описаниями,
+// а trailer'ы всех коммитов без повторов идут последним абзацем, чтобы метаданные MR брались из всей группы
+func groupDescription(messages []Message) string {
+	var (
+		b        strings.Builder
+		trailers []string
+	)
+
+	seen := make(map[string]bool)
+
+	for _, msg := range messages {
+		body, msgTrailers := splitTrailers(msg.Description)
+
+		b.WriteString("* " + msg.Subject + "\n")
+
+		if body != "" {
+			// отступ оставляет описание внутри пункта списка markdown
+			b.WriteString("\n  " + strings.ReplaceAll(body, "\n", "\n  ") + "\n\n")
+		}
+
+		for _, trailer := range msgTrailers {
+			if !seen[trailer] {
+				seen[trailer] = true
+				trailers = append(trailers, trailer)
+			}
+		}
+	}
+
+	description := strings.TrimRight(b.String(), "\n")
+
+	if len(trailers) > 0 {
+		description += "\n\n" + strings.Join(trailers, "\n")
+	}
+
+	return description
+}
+
+// splitTrailers отделяет от описания коммита последний абзац, если он состоит только из trailer'ов
+func splitTrailers(description string) (body string, trailers []string) {
+	description = strings.Trim(description, "\n")
+
+	start := strings.LastIndex(description, "\n\n") + 1
+	if start > 0 {
+		start++
+	}
+
+	for _, line := range strings.Split(description[start:], "\n") {
+		colon := strings.Index(line, ": ")
+		if colon <= 0 || strings.ContainsAny(line[:colon], " \t") {
+			return description, nil
+		}
+
+		trailers = append(trailers, line)
+	}
+
+	return strings.TrimRight(description[:start], "\n"), trailers
+}
+
+// Trailers возвращает значения trailer'а key из последнего абзаца описания коммита
+func (m Message) Trailers(key string) []string {
+	lines := strings.Split(strings.TrimRight(m.Description, "\n"), "\n")
+
+	var values []string
+
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			break
+		}
+
+		colon := strings.Index(line, ":")
+		if colon <= 0 {
+			continue
+		}
+
+		if strings.EqualFold(line[:colon], key) {
+			values = append([]string{strings.TrimSpace(line[colon+1:])}, values...)
+		}
+	}
+
+	return values
+}
+
+// Trailer возвращает последнее значение trailer'а key
+func (m Message) Trailer(key string) string {
+	values := m.Trailers(key)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[len(values)-1]
+}
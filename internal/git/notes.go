@@ -0,0 +1,96 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// commitBase возвращает коммит, от которого считаются изменения review коммита, для группы от начала группы
+func commitBase(ctx context.Context, sha, baseBranch string) (string, error) {
+	commit, err := findCommit(ctx, sha)
+	if err != nil {
+		return "", err
+	}
+
+	_, base, err := reviewDiffHash(ctx, commit, baseBranch)
+	if err != nil {
+		return "", err
+	}
+
+	if base == "" {
+		return sha + "~", nil
+	}
+
+	return base, nil
+}
+
+// interdiffNote возвращает текст заметки для MR об изменениях между старым и новым коммитом review ветки,
+// пустой текст значит, что коммит только перебазирован и ревьюерам смотреть нечего
+func interdiffNote(ctx context.Context, baseBranch, oldSHA, newSHA string) (string, error) {
+	oldBase, err := commitBase(ctx, oldSHA, baseBranch)
+	if err != nil {
+		return "", err
+	}
+
+	newBase, err := commitBase(ctx, newSHA, baseBranch)
+	if err != nil {
+		return "", err
+	}
+
+	oldPatch, err := patchLines(ctx, oldBase, oldSHA)
+	if err != nil {
+		return "", err
+	}
+
+	newPatch, err := patchLines(ctx, newBase, newSHA)
+	if err != nil {
+		return "", err
+	}
+
+	if strings.Join(oldPatch, "\n") == strings.Join(newPatch, "\n") {
+		return "", nil
+	}
+
+	// range-diff сравнивает сами изменения, поэтому новая база коммита в него не попадает
+	interdiff, err := run(ctx, "range-diff", "--no-color", "--creation-factor=100", oldBase+".."+oldSHA, newBase+".."+newSHA)
+	if err != nil {
+		return "", err
+	}
+
+	web, err := WebURL(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Updated from [%s](%s/-/commit/%s) to [%s](%s/-/commit/%s)\n\n",
+		oldSHA, web, oldSHA, newSHA, web, newSHA)
+	b.WriteString("<details><summary>Changes since the last review</summary>\n\n")
+	b.WriteString("```diff\n")
+	b.WriteString(strings.Join(interdiff, "\n"))
+	b.WriteString("\n```\n\n</details>\n")
+
+	return b.String(), nil
+}
+
+// postInterdiffNote публикует в открытый MR review ветки заметку с изменениями после push
+func postInterdiffNote(ctx context.Context, branch, oldSHA, newSHA string) error {
+	note, err := interdiffNote(ctx, reviewBranchBase(branch), oldSHA, newSHA)
+	if err != nil || note == "" {
+		return err
+	}
+
+	client, err := gitlabClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	mr, err := client.FindMergeRequest(ctx, branch)
+	if err != nil || mr == nil {
+		return err
+	}
+
+	return client.CreateNote(ctx, mr.IID, note)
+}
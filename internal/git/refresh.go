@@ -1,6 +1,10 @@
 package git
 
-import "context"
+import (
+	"context"
+
+	"github.com/waffleboot/giiter/internal/app"
+)
 
 func Refresh(ctx context.Context, baseBranch, featureBranch string) ([]Record, error) {
 	records, err := State(ctx, baseBranch, featureBranch)
@@ -8,23 +12,41 @@ func Refresh(ctx context.Context, baseBranch, featureBranch string) ([]Record, e
 		return nil, err
 	}
 
-	// переключить feature коммиты на найденные review ветки
+	// переключить feature коммиты на найденные review ветки,
+	// MR переключаемых веток перецепляются на предыдущую запись, порядок записей мог поменяться
+
+	prevBranch, err := StackBase(ctx, baseBranch)
+	if err != nil {
+		return nil, err
+	}
 
 	for i := range records {
 		record := records[i]
-		if record.IsOldCommit() || record.IsNewCommit() || record.MatchedCommit() {
+		if record.IsOldCommit() || record.IsSkipped() {
 			continue
 		}
 
-		for _, branch := range record.ReviewBranchNames() {
-			if errSwitch := SwitchBranch(ctx, branch, record.featureSHA); errSwitch != nil {
-				return nil, errSwitch
+		if record.IsNewCommit() {
+			prevBranch = ReviewBranchName(featureBranch, record.NewID)
+
+			continue
+		}
+
+		if !record.MatchedCommit() {
+			for _, branch := range record.ReviewBranchNames() {
+				if errSwitch := switchBranch(ctx, branch, record.featureSHA, prevBranch); errSwitch != nil {
+					return nil, errSwitch
+				}
 			}
+
+			records[i].switchBranch()
 		}
 
-		records[i].switchBranch()
+		prevBranch = record.ReviewBranchNames()[0]
 	}
 
+	rememberChangeIDs(featureBranch, records)
+
 	// если хотя бы один новый коммит не сопоставленный остался, то заброшенные review ветки не удаляем
 	// чтобы можно было сделать ручной assign коммитов на эти ветки, чтобы не потерять review comments
 
@@ -41,7 +63,7 @@ func Refresh(ctx context.Context, baseBranch, featureBranch string) ([]Record, e
 
 	for _, record := range records {
 		if record.IsOldCommit() {
-			if err := deleteReviewBranches(ctx, record); err != nil {
+			if err := deleteReviewBranches(ctx, featureBranch, record); err != nil {
 				return nil, err
 			}
 
@@ -55,12 +77,30 @@ func Refresh(ctx context.Context, baseBranch, featureBranch string) ([]Record, e
 	return records[:j], nil
 }
 
-func deleteReviewBranches(ctx context.Context, record Record) error {
+func deleteReviewBranches(ctx context.Context, featureBranch string, record Record) error {
 	for _, branch := range record.ReviewBranchNames() {
 		if err := DeleteBranch(ctx, branch); err != nil {
 			return err
 		}
+
+		ForgetReviewBranch(featureBranch, branch)
 	}
 
 	return nil
 }
+
+func rememberChangeIDs(featureBranch string, records []Record) {
+	if !app.Config.UseChangeID {
+		return
+	}
+
+	for i := range records {
+		if records[i].IsOldCommit() || !records[i].HasReview() {
+			continue
+		}
+
+		for _, branch := range records[i].ReviewBranchNames() {
+			RememberChangeID(featureBranch, records[i].ChangeID(), branch)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// WebURL возвращает адрес проекта в веб интерфейсе по адресу origin
+func WebURL(ctx context.Context) (string, error) {
+	output, err := run(ctx, "remote", "get-url", "origin")
+	if err != nil {
+		return "", err
+	}
+
+	if len(output) == 0 {
+		return "", errors.New("origin remote is not configured")
+	}
+
+	return webURL(output[0])
+}
+
+// webURL переводит адрес git репозитория в адрес веб интерфейса:
+// git@host:group/project.git, ssh://git@host:22/group/project.git и https://host/group/project.git
+func webURL(remote string) (string, error) {
+	remote = strings.TrimSuffix(remote, ".git")
+
+	if !strings.Contains(remote, "://") {
+		i := strings.Index(remote, ":")
+		if i < 0 {
+			return "", fmt.Errorf("unsupported remote %s", remote)
+		}
+
+		host := remote[:i]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+
+		return "https://" + host + "/" + strings.TrimPrefix(remote[i+1:], "/"), nil
+	}
+
+	u, err := url.Parse(remote)
+	if err != nil {
+		return "", err
+	}
+
+	scheme := u.Scheme
+	if scheme != "http" {
+		scheme = "https"
+	}
+
+	return scheme + "://" + u.Hostname() + u.Path, nil
+}
+
+// MergeRequestURL возвращает адрес открытых MR review ветки
+func MergeRequestURL(ctx context.Context, branch string) (string, error) {
+	web, err := WebURL(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return web + "/-/merge_requests?scope=all&state=opened&source_branch=" + url.QueryEscape(branch), nil
+}
@@ -0,0 +1,340 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/waffleboot/giiter/internal/app"
+)
+
+const (
+	todoPick  = "pick"
+	todoFixup = "fixup"
+	todoEdit  = "edit"
+)
+
+// todoEntry строка плана перестройки feature ветки, одна запись может состоять из нескольких коммитов
+type todoEntry struct {
+	action string
+	record int
+}
+
+// stackTodo возвращает план, который оставляет feature ветку как есть. Merge base ветки в план не попадает:
+// rebase идет от merge-base, в который этот merge уже привел изменения base ветки
+func stackTodo(records []Record) ([]todoEntry, error) {
+	entries := make([]todoEntry, 0, len(records))
+
+	for i := range records {
+		if records[i].IsOldCommit() || isMergeFromBase(&records[i]) {
+			continue
+		}
+
+		if records[i].IsMergeCommit() {
+			return nil, fmt.Errorf("record %d is merge commit, rewrite feature branch manually", i+1)
+		}
+
+		entries = append(entries, todoEntry{action: todoPick, record: i})
+	}
+
+	return entries, nil
+}
+
+func todoPosition(entries []todoEntry, record int) (int, error) {
+	for i := range entries {
+		if entries[i].record == record {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("record %d has no feature commit", record+1)
+}
+
+// Move переносит запись from на место записи to
+func Move(ctx context.Context, baseBranch, featureBranch string, records []Record, from, to int) error {
+	if from == to {
+		return errors.New("you point the same record")
+	}
+
+	entries, err := stackTodo(records)
+	if err != nil {
+		return err
+	}
+
+	if entries, err = moveTodo(entries, from, to); err != nil {
+		return err
+	}
+
+	return rewriteStack(ctx, baseBranch, featureBranch, records, entries)
+}
+
+func moveTodo(entries []todoEntry, from, to int) ([]todoEntry, error) {
+	i, err := todoPosition(entries, from)
+	if err != nil {
+		return nil, err
+	}
+
+	j, err := todoPosition(entries, to)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := entries[i]
+	entries = append(entries[:i], entries[i+1:]...)
+
+	return append(entries[:j], append([]todoEntry{entry}, entries[j:]...)...), nil
+}
+
+// Drop удаляет записи из feature ветки
+func Drop(ctx context.Context, baseBranch, featureBranch string, records []Record, positions ...int) error {
+	entries, err := stackTodo(records)
+	if err != nil {
+		return err
+	}
+
+	for _, pos := range positions {
+		// пропущенный коммит не виден в review, его удаление легко не заметить
+		if records[pos].IsSkipped() {
+			return fmt.Errorf("record %d is skipped, could not drop it", pos+1)
+		}
+	}
+
+	if entries, err = dropTodo(entries, positions); err != nil {
+		return err
+	}
+
+	return rewriteStack(ctx, baseBranch, featureBranch, records, entries)
+}
+
+func dropTodo(entries []todoEntry, positions []int) ([]todoEntry, error) {
+	dropped := make(map[int]bool, len(positions))
+
+	for _, pos := range positions {
+		i, err := todoPosition(entries, pos)
+		if err != nil {
+			return nil, err
+		}
+
+		dropped[i] = true
+	}
+
+	kept := entries[:0]
+
+	for i := range entries {
+		if !dropped[i] {
+			kept = append(kept, entries[i])
+		}
+	}
+
+	return kept, nil
+}
+
+// Squash добавляет изменения записи pos в запись into, сообщение коммита остается от into
+func Squash(ctx context.Context, baseBranch, featureBranch string, records []Record, pos, into int) error {
+	if pos == into {
+		return errors.New("you point the same record")
+	}
+
+	entries, err := stackTodo(records)
+	if err != nil {
+		return err
+	}
+
+	if entries, err = squashTodo(entries, pos, into); err != nil {
+		return err
+	}
+
+	return rewriteStack(ctx, baseBranch, featureBranch, records, entries)
+}
+
+func squashTodo(entries []todoEntry, pos, into int) ([]todoEntry, error) {
+	i, err := todoPosition(entries, pos)
+	if err != nil {
+		return nil, err
+	}
+
+	entries = append(entries[:i], entries[i+1:]...)
+
+	j, err := todoPosition(entries, into)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := todoEntry{action: todoFixup, record: pos}
+
+	return append(entries[:j+1], append([]todoEntry{entry}, entries[j+1:]...)...), nil
+}
+
+func writeTodo(records []Record, entries []todoEntry) (string, error) {
+	return writeTodoFile(todoText(records, entries))
+}
+
+// writeTodoFile сохраняет план во временный файл для sequence.editor
+func writeTodoFile(todo string) (string, error) {
+	f, err := os.CreateTemp("", "giiter-todo-")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(todo); err != nil {
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+// todoText возвращает план для rebase -i
+func todoText(records []Record, entries []todoEntry) string {
+	var todo strings.Builder
+
+	for _, entry := range entries {
+		action := entry.action
+
+		for _, commit := range records[entry.record].Commits() {
+			fmt.Fprintf(&todo, "%s %s\n", action, commit)
+
+			if action == todoFixup {
+				continue
+			}
+
+			// остальные коммиты группы идут отдельными коммитами
+			action = todoPick
+		}
+	}
+
+	return todo.String()
+}
+
+// rewriteStack перестраивает feature ветку по плану, review ветки переключаются
+// на новые коммиты своих записей, а MR перецепляются в новом порядке
+func rewriteStack(ctx context.Context, baseBranch, featureBranch string, records []Record, entries []todoEntry) error {
+	if err := checkNoPendingOperation(); err != nil {
+		return err
+	}
+
+	todo, err := writeTodo(records, entries)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(todo)
+
+	output, err := run(ctx, "merge-base", baseBranch, featureBranch)
+	if err != nil {
+		return err
+	}
+
+	head, err := revParse(ctx, featureBranch)
+	if err != nil {
+		return err
+	}
+
+	op := &app.Operation{
+		BaseBranch:    baseBranch,
+		FeatureBranch: featureBranch,
+		Head:          head,
+	}
+
+	if err := runRebase(ctx, op,
+		"-c", fmt.Sprintf("sequence.editor=cp '%s'", todo), "rebase", "-i", output[0], featureBranch,
+	); err != nil {
+		return err
+	}
+
+	tips, err := newRecordTips(ctx, baseBranch, featureBranch, records, entries)
+	if err != nil {
+		return err
+	}
+
+	if err := followRecords(ctx, baseBranch, records, entries, tips); err != nil {
+		return err
+	}
+
+	return restackChildren(ctx, featureBranch, head)
+}
+
+// newRecordTips сопоставляет записям их новые коммиты, fixup коммиты новых коммитов не дают,
+// поэтому запись в которую добавлен fixup заканчивается на своем последнем коммите.
+// Если git выбросил опустевшие коммиты, то сопоставить нельзя и остается только diff hash
+func newRecordTips(
+	ctx context.Context,
+	baseBranch, featureBranch string,
+	records []Record,
+	entries []todoEntry,
+) (map[int]string, error) {
+	commits, err := allCommits(ctx, baseBranch, featureBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	var picks int
+
+	for _, entry := range entries {
+		if entry.action == todoPick {
+			picks += len(records[entry.record].Commits())
+		}
+	}
+
+	if picks != len(commits) {
+		return nil, nil
+	}
+
+	tips := make(map[int]string)
+
+	var next int
+
+	for _, entry := range entries {
+		if entry.action != todoPick {
+			continue
+		}
+
+		next += len(records[entry.record].Commits())
+		tips[entry.record] = commits[next-1]
+	}
+
+	return tips, nil
+}
+
+func followRecords(
+	ctx context.Context,
+	baseBranch string,
+	records []Record,
+	entries []todoEntry,
+	tips map[int]string,
+) error {
+	if tips == nil {
+		return nil
+	}
+
+	prevBranch, err := StackBase(ctx, baseBranch)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.action != todoPick {
+			continue
+		}
+
+		record := &records[entry.record]
+		if !record.HasReview() || record.IsSkipped() {
+			continue
+		}
+
+		branch, err := record.AnyReviewBranch()
+		if err != nil {
+			return errors.WithMessagef(err, "record %d", entry.record+1)
+		}
+
+		if err := switchBranch(ctx, branch, tips[entry.record], prevBranch); err != nil {
+			return err
+		}
+
+		prevBranch = branch
+	}
+
+	return nil
+}
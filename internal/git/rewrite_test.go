@@ -0,0 +1,113 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// todoRecords записи для планов rebase:
+// 1) 1111111 lexer
+// 2) 2222222 3333333 группа ast
+// 3) 5555555 merge from master, пропущен
+// 4) 6666666 docs
+// 5) -- старая запись
+func todoRecords() []Record {
+	return []Record{
+		{featureSHA: "1111111", commits: []string{"1111111"}},
+		{featureSHA: "3333333", commits: []string{"2222222", "3333333"}, group: "ast"},
+		{featureSHA: "5555555", commits: []string{"5555555"}, skipReason: "merge from master", merge: true},
+		{featureSHA: "6666666", commits: []string{"6666666"}},
+		{reviewSHA: "9999999"},
+	}
+}
+
+func TestStackTodo(t *testing.T) {
+	entries, err := stackTodo(todoRecords())
+	require.NoError(t, err)
+	require.Equal(t, []todoEntry{
+		{action: todoPick, record: 0},
+		{action: todoPick, record: 1},
+		{action: todoPick, record: 3},
+	}, entries)
+
+	records := todoRecords()
+	records[2].skipReason = ""
+
+	_, err = stackTodo(records)
+	require.EqualError(t, err, "record 3 is merge commit, rewrite feature branch manually")
+}
+
+func TestTodoBuilders(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func([]todoEntry) ([]todoEntry, error)
+		todo  string
+		err   string
+	}{
+		{
+			name:  "keep",
+			build: func(entries []todoEntry) ([]todoEntry, error) { return entries, nil },
+			todo:  "pick 1111111\npick 2222222\npick 3333333\npick 6666666\n",
+		},
+		{
+			name:  "move down",
+			build: func(entries []todoEntry) ([]todoEntry, error) { return moveTodo(entries, 0, 3) },
+			todo:  "pick 2222222\npick 3333333\npick 6666666\npick 1111111\n",
+		},
+		{
+			name:  "move group up",
+			build: func(entries []todoEntry) ([]todoEntry, error) { return moveTodo(entries, 1, 0) },
+			todo:  "pick 2222222\npick 3333333\npick 1111111\npick 6666666\n",
+		},
+		{
+			name:  "drop",
+			build: func(entries []todoEntry) ([]todoEntry, error) { return dropTodo(entries, []int{0, 3}) },
+			todo:  "pick 2222222\npick 3333333\n",
+		},
+		{
+			name:  "squash into group",
+			build: func(entries []todoEntry) ([]todoEntry, error) { return squashTodo(entries, 0, 1) },
+			todo:  "pick 2222222\npick 3333333\nfixup 1111111\npick 6666666\n",
+		},
+		{
+			name:  "squash group",
+			build: func(entries []todoEntry) ([]todoEntry, error) { return squashTodo(entries, 1, 3) },
+			todo:  "pick 1111111\npick 6666666\nfixup 2222222\nfixup 3333333\n",
+		},
+		{
+			name:  "move merge from base",
+			build: func(entries []todoEntry) ([]todoEntry, error) { return moveTodo(entries, 2, 0) },
+			err:   "record 3 has no feature commit",
+		},
+		{
+			name:  "drop old record",
+			build: func(entries []todoEntry) ([]todoEntry, error) { return dropTodo(entries, []int{4}) },
+			err:   "record 5 has no feature commit",
+		},
+		{
+			name:  "squash into old record",
+			build: func(entries []todoEntry) ([]todoEntry, error) { return squashTodo(entries, 0, 4) },
+			err:   "record 5 has no feature commit",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := todoRecords()
+
+			entries, err := stackTodo(records)
+			require.NoError(t, err)
+
+			entries, err = tt.build(entries)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.todo, todoText(records, entries))
+		})
+	}
+}
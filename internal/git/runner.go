@@ -1,6 +1,17 @@
 package git
 
-import "context"
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/waffleboot/giiter/internal/output"
+)
 
 type runner struct{}
 
@@ -11,3 +22,75 @@ func (r runner) AllBranches(ctx context.Context) ([]string, error) {
 func (r runner) ChangedFiles(ctx context.Context, sha string) ([]string, error) {
 	return run(ctx, "diff-tree", "-r", "--name-only", "-c", sha)
 }
+
+func (r runner) Run(ctx context.Context, args ...string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+
+	// cmd.Dir = app.Config.Repo
+
+	stdOut := new(bytes.Buffer)
+	stdErr := new(bytes.Buffer)
+
+	cmd.Stdout = stdOut
+	cmd.Stderr = stdErr
+
+	errRun := cmd.Run()
+
+	stdOutLines, errParseStdOut := bytesBufferToSlice(stdOut)
+	stdErrLines, errParseErrOut := bytesBufferToSlice(stdErr)
+
+	if errParseStdOut != nil {
+		output.Warnf("%v", errParseStdOut)
+	}
+
+	if errParseErrOut != nil {
+		output.Warnf("%v", errParseErrOut)
+	}
+
+	if errRun != nil {
+		return nil, ErrRun{
+			stdOutput: stdOutLines,
+			errOutput: stdErrLines,
+			err:       errRun,
+		}
+	}
+
+	return stdOutLines, nil
+}
+
+func bytesBufferToSlice(buf *bytes.Buffer) ([]string, error) {
+	var output []string
+
+	scanner := bufio.NewScanner(buf)
+
+	for scanner.Scan() {
+		output = append(output, scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+func (r runner) RunTo(ctx context.Context, w io.Writer, args ...string) error {
+	// pager запускает giiter, чтобы заголовки записей попали в него вместе с выводом git
+	cmd := exec.CommandContext(ctx, "git", append([]string{"--no-pager"}, args...)...)
+
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = w
+	cmd.Stderr = output.ErrWriter()
+
+	err := cmd.Run()
+
+	// pager закрыли раньше, чем git все вывел, это не ошибка, git сам так себя ведет
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() && status.Signal() == syscall.SIGPIPE {
+			return nil
+		}
+	}
+
+	return err
+}
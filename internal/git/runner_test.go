@@ -0,0 +1,276 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/waffleboot/giiter/internal/app"
+	"github.com/waffleboot/giiter/internal/git/mocks"
+)
+
+type fakeCommit struct {
+	parents []string
+	subject string
+	body    string
+	files   []string
+}
+
+type fakeReply struct {
+	output []string
+	err    error
+	// apply меняет ветки и коммиты, как это сделала бы команда
+	apply func()
+}
+
+// fakeRepo отвечает на команды git, которые читают ветки и коммиты, по их описанию,
+// остальные команды тест задает через on, они запоминаются в changes в порядке вызова
+type fakeRepo struct {
+	t        *testing.T
+	branches map[string]string
+	commits  map[string]fakeCommit
+	replies  map[string]fakeReply
+	changes  []string
+	// todo план последнего rebase -i, giiter передает его через sequence.editor
+	todo string
+	// rebasing rebase остановился и ждет giiter continue
+	rebasing bool
+}
+
+// newFakeRepo подменяет git пакета на fakeRepo до конца теста, там же восстанавливаются настройки giiter
+func newFakeRepo(t *testing.T) *fakeRepo {
+	f := &fakeRepo{
+		t:        t,
+		branches: make(map[string]string),
+		commits:  make(map[string]fakeCommit),
+		replies:  make(map[string]fakeReply),
+	}
+
+	mock := mocks.NewGitRunnerMock(t)
+	mock.AllBranchesMock.Set(func(ctx context.Context) ([]string, error) {
+		return f.allBranches(), nil
+	})
+	mock.ChangedFilesMock.Set(func(ctx context.Context, sha string) ([]string, error) {
+		// diff-tree -c сначала выводит SHA коммита
+		return append([]string{sha}, f.commit(sha).files...), nil
+	})
+	mock.RunMock.Set(f.run)
+	mock.RunToMock.Set(func(ctx context.Context, w io.Writer, args ...string) error {
+		output, err := f.run(ctx, args...)
+		for _, line := range output {
+			fmt.Fprintln(w, line)
+		}
+
+		return err
+	})
+
+	savedRunner, savedConfig, savedDelay := gitRunner, app.Config, pushDelay
+	gitRunner, pushDelay = mock, 0
+	app.Config.EnableGitPush = true
+
+	t.Cleanup(func() {
+		gitRunner, app.Config, pushDelay = savedRunner, savedConfig, savedDelay
+	})
+
+	return f
+}
+
+// add добавляет коммиты "<sha> <subject>" друг за другом, первый коммит строится на parent,
+// возвращает последний коммит
+func (f *fakeRepo) add(parent string, commits ...string) string {
+	for _, line := range commits {
+		fs := strings.SplitN(line, " ", 2)
+		f.commits[fs[0]] = fakeCommit{parents: []string{parent}, subject: fs[1], files: []string{fs[0] + ".go"}}
+		parent = fs[0]
+	}
+
+	return parent
+}
+
+func (f *fakeRepo) on(command string, output ...string) {
+	f.replies[command] = fakeReply{output: output}
+}
+
+func (f *fakeRepo) onApply(command string, apply func()) {
+	f.replies[command] = fakeReply{apply: apply}
+}
+
+func (f *fakeRepo) fail(command string, err error) {
+	f.replies[command] = fakeReply{err: err}
+}
+
+func (f *fakeRepo) commit(sha string) fakeCommit {
+	commit, ok := f.commits[sha]
+	if !ok {
+		f.t.Errorf("unknown commit %s", sha)
+	}
+
+	return commit
+}
+
+func (f *fakeRepo) allBranches() []string {
+	names := make([]string, 0, len(f.branches))
+	for name := range f.branches {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	output := make([]string, 0, len(names))
+	for _, name := range names {
+		output = append(output, f.branches[name]+" "+name)
+	}
+
+	return output
+}
+
+// resolve понимает имя ветки, SHA и суффиксы ~
+func (f *fakeRepo) resolve(rev string) string {
+	parents := strings.Count(rev, "~")
+	rev = strings.TrimPrefix(strings.TrimRight(rev, "~"), "refs/heads/")
+
+	if sha, ok := f.branches[rev]; ok {
+		rev = sha
+	}
+
+	for ; parents > 0; parents-- {
+		rev = f.commit(rev).parents[0]
+	}
+
+	return rev
+}
+
+// firstParents возвращает коммиты tip, которых нет в base, от новых к старым
+func (f *fakeRepo) firstParents(base, tip string) []string {
+	reachable := make(map[string]bool)
+
+	for sha := f.resolve(base); sha != ""; {
+		reachable[sha] = true
+
+		parents := f.commits[sha].parents
+		if len(parents) == 0 {
+			break
+		}
+
+		sha = parents[0]
+	}
+
+	var shas []string
+
+	for sha := f.resolve(tip); sha != "" && !reachable[sha]; {
+		shas = append(shas, sha)
+
+		parents := f.commit(sha).parents
+		if len(parents) == 0 {
+			break
+		}
+
+		sha = parents[0]
+	}
+
+	return shas
+}
+
+func (f *fakeRepo) run(_ context.Context, args ...string) ([]string, error) {
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "sequence.editor=cp '") {
+			todo, err := os.ReadFile(strings.TrimSuffix(strings.TrimPrefix(arg, "sequence.editor=cp '"), "'"))
+			if err != nil {
+				f.t.Errorf("read todo: %v", err)
+			}
+
+			f.todo = string(todo)
+			args[i] = "sequence.editor=cp <todo>"
+		}
+	}
+
+	command := strings.Join(args, " ")
+
+	if reply, ok := f.replies[command]; ok {
+		f.changes = append(f.changes, command)
+
+		if reply.apply != nil {
+			reply.apply()
+		}
+
+		return reply.output, reply.err
+	}
+
+	switch {
+	case len(args) == 4 && args[0] == "branch" && args[1] == "-f", len(args) == 3 && args[0] == "branch":
+		f.changes = append(f.changes, command)
+		f.branches[args[len(args)-2]] = f.resolve(args[len(args)-1])
+
+		return nil, nil
+	case args[0] == "push":
+		f.changes = append(f.changes, command)
+
+		return nil, nil
+	case len(args) == 4 && args[0] == "rev-parse" && args[1] == "--verify":
+		sha, ok := f.branches[strings.TrimPrefix(args[3], "refs/heads/")]
+		if !ok {
+			return nil, exitError(1)
+		}
+
+		return []string{sha}, nil
+	case command == "rev-parse --git-path rebase-merge" && f.rebasing:
+		return []string{f.t.TempDir()}, nil
+	case command == "rev-parse --git-path rebase-merge" || command == "rev-parse --git-path rebase-apply":
+		return []string{"/nonexistent/" + args[2]}, nil
+	case len(args) == 3 && args[0] == "rev-parse" && args[1] == "--short":
+		return []string{f.resolve(args[2])}, nil
+	case len(args) == 4 && args[0] == "log" && args[1] == "--pretty=format:%h" && args[2] == "--first-parent":
+		revs := strings.SplitN(args[3], "..", 2)
+
+		return f.firstParents(revs[0], revs[1]), nil
+	case len(args) == 4 && args[0] == "log" && args[1] == "--pretty=format:%p%n%s%n%b":
+		commit := f.commit(f.resolve(args[2]))
+		output := []string{strings.Join(commit.parents, " "), commit.subject}
+
+		if commit.body != "" {
+			output = append(output, strings.Split(commit.body, "\n")...)
+		}
+
+		return output, nil
+	case len(args) == 5 && args[0] == "rev-list" && args[1] == "--parents":
+		sha := f.resolve(args[4])
+
+		return []string{strings.Join(append([]string{sha}, f.commit(sha).parents...), " ")}, nil
+	case len(args) == 3 && args[0] == "merge-base":
+		shas := f.firstParents(args[1], args[2])
+		if len(shas) == 0 {
+			return []string{f.resolve(args[2])}, nil
+		}
+
+		return []string{f.commit(shas[len(shas)-1]).parents[0]}, nil
+	case len(args) == 5 && args[0] == "diff-tree" && args[2] == "--name-only":
+		var files []string
+		for _, sha := range f.firstParents(args[3], args[4]) {
+			files = append(files, f.commit(sha).files...)
+		}
+
+		return files, nil
+	case len(args) == 6 && args[0] == "diff-tree" && args[1] == "--unified=0" && args[2] != "-c":
+		return []string{"diff --git", "index 1..2", "--- a", "+++ b", "@@ -1 +1 @@", "+" + args[5]}, nil
+	case len(args) == 6 && args[0] == "diff-tree" && args[2] == "-c":
+		// diff коммита: строка SHA, заголовок файла и изменение, которое отличает коммит от других
+		commit := f.commit(args[3])
+
+		return []string{args[3], "diff --git", "index 1..2", "--- a", "+++ b", "@@ -1 +1 @@", "+" + commit.subject}, nil
+	}
+
+	f.t.Errorf("unexpected git %s", command)
+
+	return nil, errors.New("unexpected git " + command)
+}
+
+// exitError возвращает ошибку завершения процесса с кодом code, как у git
+func exitError(code int) error {
+	return exec.Command("sh", "-c", fmt.Sprintf("exit %d", code)).Run()
+}
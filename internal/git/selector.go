@@ -0,0 +1,202 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// minSHAPrefix минимальная длина префикса SHA в селекторе @sha
+const minSHAPrefix = 4
+
+// Select возвращает индексы записей, выбранных селектором:
+// 3 позиция из list, @c205c0d префикс SHA, #5 номер review ветки, /parser/ регулярное выражение по subject,
+// 2..4 диапазон из двух селекторов, каждый из которых выбирает одну запись
+func Select(records []Record, selector string) ([]int, error) {
+	if isRange(selector) {
+		sep := strings.Index(selector, "..")
+
+		i, err := SelectOne(records, selector[:sep])
+		if err != nil {
+			return nil, err
+		}
+
+		j, err := SelectOne(records, selector[sep+2:])
+		if err != nil {
+			return nil, err
+		}
+
+		if i > j {
+			return nil, fmt.Errorf("range %s is reversed", selector)
+		}
+
+		indexes := make([]int, 0, j-i+1)
+		for k := i; k <= j; k++ {
+			indexes = append(indexes, k)
+		}
+
+		return indexes, nil
+	}
+
+	var match func(*Record) bool
+
+	switch {
+	case strings.HasPrefix(selector, "@"):
+		prefix := strings.ToLower(selector[1:])
+		if len(prefix) < minSHAPrefix {
+			return nil, fmt.Errorf("%s: SHA prefix needs at least %d characters", selector, minSHAPrefix)
+		}
+
+		match = func(r *Record) bool { return r.hasSHA(prefix) }
+	case strings.HasPrefix(selector, "#"):
+		id, err := strconv.Atoi(selector[1:])
+		if err != nil {
+			return nil, fmt.Errorf("%s: review branch id is not a number", selector)
+		}
+
+		match = func(r *Record) bool { return r.hasReviewID(id) }
+	case len(selector) > 1 && strings.HasPrefix(selector, "/") && strings.HasSuffix(selector, "/"):
+		re, err := regexp.Compile(selector[1 : len(selector)-1])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", selector, err)
+		}
+
+		match = func(r *Record) bool { return re.MatchString(r.CommitMessage().Subject) }
+	default:
+		pos, err := strconv.Atoi(selector)
+		if err != nil {
+			return nil, fmt.Errorf("unknown selector %s, use 3, @sha, #id, /regexp/ or 2..4", selector)
+		}
+
+		if pos < 1 || pos > len(records) {
+			return nil, fmt.Errorf("position %d is out of range 1..%d", pos, len(records))
+		}
+
+		return []int{pos - 1}, nil
+	}
+
+	var indexes []int
+
+	for i := range records {
+		if match(&records[i]) {
+			indexes = append(indexes, i)
+		}
+	}
+
+	if len(indexes) == 0 {
+		if strings.HasPrefix(selector, "#") {
+			return nil, fmt.Errorf("%s matches no records, review branch ids are %s", selector, reviewIDs(records))
+		}
+
+		return nil, fmt.Errorf("%s matches no records", selector)
+	}
+
+	return indexes, nil
+}
+
+func isRange(selector string) bool {
+	return strings.Contains(selector, "..") && !strings.HasPrefix(selector, "/")
+}
+
+// SelectRange возвращает индексы записей явного диапазона 2..4, остальные селекторы должны выбирать одну запись,
+// чтобы /regexp/ случайно не захватил несколько записей
+func SelectRange(records []Record, selector string) ([]int, error) {
+	if isRange(selector) {
+		return Select(records, selector)
+	}
+
+	index, err := SelectOne(records, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	return []int{index}, nil
+}
+
+func reviewIDs(records []Record) string {
+	var ids []string
+
+	for i := range records {
+		for _, branch := range records[i].reviewBranches {
+			ids = append(ids, "#"+strconv.Itoa(branch.id))
+		}
+	}
+
+	if len(ids) == 0 {
+		return "none"
+	}
+
+	return strings.Join(ids, ",")
+}
+
+// SelectOne возвращает индекс единственной записи, выбранной селектором
+func SelectOne(records []Record, selector string) (int, error) {
+	indexes, err := Select(records, selector)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(indexes) > 1 {
+		positions := make([]string, 0, len(indexes))
+		for _, i := range indexes {
+			positions = append(positions, strconv.Itoa(i+1))
+		}
+
+		return 0, fmt.Errorf("%s is ambiguous, it matches records %s", selector, strings.Join(positions, ","))
+	}
+
+	return indexes[0], nil
+}
+
+// hasSHA проверяет коммиты записи и ее review веток, SHA в записи короткие, а в селекторе может быть полный
+func (r *Record) hasSHA(prefix string) bool {
+	shas := append([]string{r.featureSHA, r.reviewSHA}, r.commits...)
+
+	for _, sha := range shas {
+		if sha == "" {
+			continue
+		}
+
+		if strings.HasPrefix(sha, prefix) || strings.HasPrefix(prefix, sha) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Record) hasReviewID(id int) bool {
+	for _, branch := range r.reviewBranches {
+		if branch.id == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ResolveAssignment выбирает новый коммит и запись с review веткой, на которую его нужно переключить
+func ResolveAssignment(records []Record, commitSelector, branchSelector string) (commit, branch int, err error) {
+	if commit, err = SelectOne(records, commitSelector); err != nil {
+		return 0, 0, err
+	}
+
+	if branch, err = SelectOne(records, branchSelector); err != nil {
+		return 0, 0, err
+	}
+
+	switch {
+	case commit == branch:
+		return 0, 0, errors.New("you point the same record")
+	case records[commit].IsSkipped():
+		return 0, 0, fmt.Errorf("could not reassign skipped commit %s", commitSelector)
+	case records[commit].HasReview():
+		return 0, 0, fmt.Errorf("could not reassign commit %s with review", commitSelector)
+	case !records[branch].HasReview():
+		return 0, 0, fmt.Errorf("could not reassign commit %s without review", branchSelector)
+	}
+
+	return commit, branch, nil
+}
@@ -0,0 +1,191 @@
+package git
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/waffleboot/giiter/internal/app"
+)
+
+// newStackRepo описывает feature ветку fa, ее записи:
+// 1) ok 1111111 [fa/1] parser: add lexer
+// 2) ok 2222222 [fa/2] parser: add ast
+// 3) ++ 3333333 docs
+// 4) skip 4444444 fixup! docs
+// 5) -- 9999999 [fa/3] removed
+func newStackRepo(t *testing.T) *fakeRepo {
+	f := newFakeRepo(t)
+
+	f.commits["abcdef0"] = fakeCommit{subject: "base", files: []string{"base.go"}}
+	f.add("abcdef0", "1111111 parser: add lexer", "2222222 parser: add ast", "3333333 docs", "4444444 fixup! docs")
+	f.add("abcdef0", "9999999 removed")
+
+	f.branches = map[string]string{
+		"master":      "abcdef0",
+		"fa":          "4444444",
+		"review/fa/1": "1111111",
+		"review/fa/2": "2222222",
+		"review/fa/3": "9999999",
+	}
+
+	app.Config.Shared.Filters.SkipFixup = true
+
+	return f
+}
+
+// testRecords собирает записи newStackRepo через State
+func testRecords(t *testing.T) []Record {
+	newStackRepo(t)
+
+	records, err := State(context.Background(), "master", "fa")
+	require.NoError(t, err)
+
+	return records
+}
+
+func TestSelect(t *testing.T) {
+	records := testRecords(t)
+
+	tests := []struct {
+		selector string
+		indexes  []int
+		err      string
+	}{
+		{selector: "1", indexes: []int{0}},
+		{selector: "5", indexes: []int{4}},
+		{selector: "0", err: "position 0 is out of range 1..5"},
+		{selector: "6", err: "position 6 is out of range 1..5"},
+		{selector: "-1", err: "position -1 is out of range 1..5"},
+		{selector: "@3333", indexes: []int{2}},
+		{selector: "@33333330123456789", indexes: []int{2}},
+		{selector: "@9999999", indexes: []int{4}},
+		{selector: "@333", err: "at least 4 characters"},
+		{selector: "@5555", err: "@5555 matches no records"},
+		{selector: "#2", indexes: []int{1}},
+		{selector: "#3", indexes: []int{4}},
+		{selector: "#7", err: "#7 matches no records, review branch ids are #1,#2,#3"},
+		{selector: "#x", err: "not a number"},
+		{selector: "/^parser/", indexes: []int{0, 1}},
+		{selector: "/ast$/", indexes: []int{1}},
+		{selector: "/[/", err: "error parsing regexp"},
+		{selector: "2..4", indexes: []int{1, 2, 3}},
+		{selector: "#1..@3333", indexes: []int{0, 1, 2}},
+		{selector: "4..2", err: "range 4..2 is reversed"},
+		{selector: "1../parser/", err: "/parser/ is ambiguous, it matches records 1,2"},
+		{selector: "1..9", err: "position 9 is out of range 1..5"},
+		{selector: "x", err: "unknown selector x"},
+		{selector: "", err: "unknown selector"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			indexes, err := Select(records, tt.selector)
+			if tt.err != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.indexes, indexes)
+		})
+	}
+}
+
+func TestSelectOne(t *testing.T) {
+	records := testRecords(t)
+
+	tests := []struct {
+		selector string
+		index    int
+		err      string
+	}{
+		{selector: "3", index: 2},
+		{selector: "/lexer/", index: 0},
+		{selector: "/parser/", err: "/parser/ is ambiguous, it matches records 1,2"},
+		{selector: "1..2", err: "1..2 is ambiguous, it matches records 1,2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			index, err := SelectOne(records, tt.selector)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.index, index)
+		})
+	}
+}
+
+func TestSelectRange(t *testing.T) {
+	records := testRecords(t)
+
+	tests := []struct {
+		selector string
+		indexes  []int
+		err      string
+	}{
+		{selector: "3", indexes: []int{2}},
+		{selector: "1..2", indexes: []int{0, 1}},
+		{selector: "#1..2", indexes: []int{0, 1}},
+		{selector: "/^parser/", err: "/^parser/ is ambiguous, it matches records 1,2"},
+		{selector: "/[.][.]/", err: "/[.][.]/ matches no records"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			indexes, err := SelectRange(records, tt.selector)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.indexes, indexes)
+		})
+	}
+}
+
+func TestResolveAssignment(t *testing.T) {
+	records := testRecords(t)
+
+	tests := []struct {
+		name   string
+		commit string
+		branch string
+		want   [2]int
+		err    string
+	}{
+		{name: "new commit to old branch", commit: "3", branch: "5", want: [2]int{2, 4}},
+		{name: "by selectors", commit: "/^docs/", branch: "#1", want: [2]int{2, 0}},
+		{name: "commit after last record", commit: "6", branch: "1", err: "position 6 is out of range 1..5"},
+		{name: "branch after last record", commit: "3", branch: "6", err: "position 6 is out of range 1..5"},
+		{name: "zero position", commit: "0", branch: "1", err: "position 0 is out of range 1..5"},
+		{name: "same record", commit: "3", branch: "3", err: "you point the same record"},
+		{name: "skipped commit", commit: "4", branch: "1", err: "could not reassign skipped commit 4"},
+		{name: "commit with review", commit: "1", branch: "2", err: "could not reassign commit 1 with review"},
+		{name: "branch without review", commit: "3", branch: "4", err: "could not reassign commit 4 without review"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commit, branch, err := ResolveAssignment(records, tt.commit, tt.branch)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, [2]int{commit, branch})
+		})
+	}
+}
@@ -0,0 +1,418 @@
+package git
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/waffleboot/giiter/internal/app"
+	"github.com/waffleboot/giiter/internal/output"
+)
+
+const OperationSplit = "split"
+
+// Split делит коммит записи pos на два коммита. Если paths не пустой, то первая часть это изменения этих путей,
+// иначе split останавливается чтобы пользователь добавил в индекс первую часть и выполнил giiter continue
+func Split(
+	ctx context.Context,
+	baseBranch, featureBranch string,
+	records []Record,
+	pos int,
+	paths []string,
+) (stopped bool, err error) {
+	if err := checkNoPendingOperation(); err != nil {
+		return false, err
+	}
+
+	record := &records[pos]
+
+	switch {
+	case record.IsOldCommit():
+		return false, errors.New("could not split commit without feature commit")
+	case record.IsSkipped():
+		return false, errors.New("could not split skipped commit")
+	case record.IsMergeCommit() || len(record.Commits()) > 1:
+		return false, errors.New("could not split merge commit or review group")
+	}
+
+	status, err := run(ctx, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return false, err
+	}
+
+	if len(status) > 0 {
+		return false, errors.New("working tree has uncommitted changes")
+	}
+
+	head, err := revParse(ctx, featureBranch)
+	if err != nil {
+		return false, err
+	}
+
+	stackBase, err := StackBase(ctx, baseBranch)
+	if err != nil {
+		return false, err
+	}
+
+	op := &app.Operation{
+		Kind:          OperationSplit,
+		BaseBranch:    baseBranch,
+		FeatureBranch: featureBranch,
+		Commit:        record.CommitSHA(),
+		Head:          head,
+		ReviewBranch:  firstReviewBranch(record),
+		TargetBranch:  targetBranch(stackBase, records, pos),
+	}
+
+	if _, err := run(ctx, "checkout", "--detach", op.Commit); err != nil {
+		return false, err
+	}
+
+	if _, err := run(ctx, "reset", "-q", "HEAD~"); err != nil {
+		return false, err
+	}
+
+	app.Config.Persistent.Pending = op
+
+	if len(paths) == 0 {
+		return true, nil
+	}
+
+	if _, err := run(ctx, append([]string{"add", "-A", "--"}, paths...)...); err != nil {
+		return false, err
+	}
+
+	if err := finishSplit(ctx, op); err != nil {
+		return false, err
+	}
+
+	// после конфликта дочерние feature ветки переносит giiter continue
+	return false, restackChildren(ctx, featureBranch, head)
+}
+
+func firstReviewBranch(record *Record) string {
+	names := record.ReviewBranchNames()
+	if len(names) == 0 {
+		return ""
+	}
+
+	return names[0]
+}
+
+// targetBranch возвращает ветку, на которую должен смотреть MR записи pos
+func targetBranch(baseBranch string, records []Record, pos int) string {
+	for i := pos - 1; i >= 0; i-- {
+		if records[i].IsSkipped() || !records[i].HasReview() {
+			continue
+		}
+
+		return firstReviewBranch(&records[i])
+	}
+
+	return baseBranch
+}
+
+// finishSplit делает из индекса первый коммит, из остальных изменений второй
+// и переносит на них остальные коммиты feature ветки
+func finishSplit(ctx context.Context, op *app.Operation) error {
+	staged, err := hasStagedChanges(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !staged {
+		return errors.New("stage first part of commit and run giiter continue")
+	}
+
+	original, err := findCommit(ctx, op.Commit)
+	if err != nil {
+		return err
+	}
+
+	if _, err := run(ctx, "commit", "-q", "--no-verify", "-C", op.Commit); err != nil {
+		return err
+	}
+
+	first, err := revParse(ctx, "HEAD")
+	if err != nil {
+		return err
+	}
+
+	files, err := commitFiles(ctx, op.Commit)
+	if err != nil {
+		return err
+	}
+
+	for i := range files {
+		files[i] = topPathspec(files[i])
+	}
+
+	if _, err := run(ctx, append([]string{"add", "-A", "--"}, files...)...); err != nil {
+		return err
+	}
+
+	second := ""
+
+	if staged, err = hasStagedChanges(ctx); err != nil {
+		return err
+	} else if staged {
+		message, err := secondPartMessage(original.Message)
+		if err != nil {
+			return err
+		}
+
+		if _, err := run(ctx, "commit", "-q", "--no-verify", "-m", message); err != nil {
+			return err
+		}
+
+		if second, err = revParse(ctx, "HEAD"); err != nil {
+			return err
+		}
+	}
+
+	// rebase меняет вид операции, части запоминаются, чтобы continue после конфликта создал review ветку
+	op.SplitFirst, op.SplitSecond = first, second
+
+	if err := runRebase(ctx, op, "rebase", "--onto", "HEAD", op.Commit, op.FeatureBranch); err != nil {
+		return err
+	}
+
+	return finishSplitReview(ctx, op)
+}
+
+// secondPartMessage сообщение второй части split: описание и trailer'ы, в том числе Review-Group,
+// остаются от исходного коммита, а Change-Id остается у первой части, вторая получает новый
+func secondPartMessage(message Message) (string, error) {
+	subject := message.Subject + " (2)"
+
+	description := strings.Trim(message.Description, "\n")
+
+	if message.Trailer(ChangeIDTrailer) != "" {
+		lines := strings.Split(description, "\n")
+		kept := lines[:0]
+
+		for _, line := range lines {
+			if !strings.HasPrefix(strings.ToLower(line), strings.ToLower(ChangeIDTrailer)+":") {
+				kept = append(kept, line)
+			}
+		}
+
+		changeID, err := newChangeID()
+		if err != nil {
+			return "", err
+		}
+
+		description = strings.Join(append(kept, ChangeIDTrailer+": "+changeID), "\n")
+	}
+
+	if description == "" {
+		return subject, nil
+	}
+
+	return subject + "\n\n" + description, nil
+}
+
+// newChangeID создает Change-Id в формате commit-msg hook, тесты подменяют его
+var newChangeID = func() (string, error) {
+	id := make([]byte, 20)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return "I" + hex.EncodeToString(id), nil
+}
+
+// finishSplitReview раздает review ветки частям коммита после того, как rebase split завершился
+func finishSplitReview(ctx context.Context, op *app.Operation) error {
+	if op.ReviewBranch == "" || op.SplitSecond == "" {
+		return nil
+	}
+
+	return splitReviewBranch(ctx, op.FeatureBranch, op.ReviewBranch, op.TargetBranch, op.SplitFirst, op.SplitSecond)
+}
+
+// splitReviewBranch оставляет review ветку и MR на части, которая больше похожа на версию в ревью,
+// для другой части создается новая review ветка
+func splitReviewBranch(ctx context.Context, featureBranch, review, target, first, second string) error {
+	secondWins, err := secondPartReviewed(ctx, review, first, second)
+	if err != nil {
+		return err
+	}
+
+	newBranch, err := nextReviewBranch(ctx, featureBranch)
+	if err != nil {
+		return err
+	}
+
+	winner, loser := first, second
+	winnerTarget, loserTarget := "", review
+
+	if secondWins {
+		winner, loser = second, first
+		winnerTarget, loserTarget = newBranch, target
+	}
+
+	if err := CreateBranch(ctx, Branch{CommitSHA: loser, BranchName: newBranch}); err != nil {
+		return err
+	}
+
+	if err := switchBranch(ctx, review, winner, winnerTarget); err != nil {
+		return err
+	}
+
+	loserCommit, err := findCommit(ctx, loser)
+	if err != nil {
+		return err
+	}
+
+	if app.Config.UseChangeID {
+		winnerCommit, err := findCommit(ctx, winner)
+		if err != nil {
+			return err
+		}
+
+		RememberChangeID(featureBranch, winnerCommit.Message.Trailer(ChangeIDTrailer), review)
+		RememberChangeID(featureBranch, loserCommit.Message.Trailer(ChangeIDTrailer), newBranch)
+	}
+
+	output.Infof("%s stays on %s, %s created for %s\n", review, winner, newBranch, loser)
+
+	return CreateMergeRequest(ctx, MergeRequest{
+		Title:        MergeRequestTitle(loserCommit.Message.Subject),
+		SourceBranch: newBranch,
+		TargetBranch: loserTarget,
+		Description:  loserCommit.Message.Description,
+		Options:      MergeRequestOptions(featureBranch, app.MergeRequestOptions{}, loserCommit.Message),
+	})
+}
+
+// secondPartReviewed сравнивает части с коммитом review ветки: по строкам изменений, которые уже видели ревьюеры,
+// потом по общим файлам, и только потом по размеру diff
+func secondPartReviewed(ctx context.Context, review, first, second string) (bool, error) {
+	reviewSHA, err := revParse(ctx, review)
+	if err != nil {
+		return false, err
+	}
+
+	reviewBase, err := commitBase(ctx, reviewSHA, reviewBranchBase(review))
+	if err != nil {
+		return false, err
+	}
+
+	reviewPatch, err := patchLines(ctx, reviewBase, reviewSHA)
+	if err != nil {
+		return false, err
+	}
+
+	firstPatch, err := patchLines(ctx, first+"~", first)
+	if err != nil {
+		return false, err
+	}
+
+	secondPatch, err := patchLines(ctx, second+"~", second)
+	if err != nil {
+		return false, err
+	}
+
+	reviewFiles := patchFiles(reviewPatch)
+
+	firstLines, firstFiles := patchOverlap(reviewFiles, patchFiles(firstPatch))
+	secondLines, secondFiles := patchOverlap(reviewFiles, patchFiles(secondPatch))
+
+	switch {
+	case firstLines != secondLines:
+		return secondLines > firstLines, nil
+	case firstFiles != secondFiles:
+		return secondFiles > firstFiles, nil
+	}
+
+	firstSize, err := diffSize(ctx, first)
+	if err != nil {
+		return false, err
+	}
+
+	secondSize, err := diffSize(ctx, second)
+	if err != nil {
+		return false, err
+	}
+
+	return secondSize > firstSize, nil
+}
+
+// patchOverlap считает строки изменений и файлы part, которые есть в review
+func patchOverlap(review, part map[string][]string) (lines, files int) {
+	for file, partLines := range part {
+		reviewLines, ok := review[file]
+		if !ok {
+			continue
+		}
+
+		files++
+
+		counts := make(map[string]int, len(reviewLines))
+		for _, line := range reviewLines {
+			counts[line]++
+		}
+
+		for _, line := range partLines {
+			if counts[line] > 0 {
+				counts[line]--
+				lines++
+			}
+		}
+	}
+
+	return lines, files
+}
+
+// diffSize возвращает количество измененных строк коммита
+func diffSize(ctx context.Context, sha string) (int, error) {
+	output, err := run(ctx, "diff", "--numstat", sha+"~", sha)
+	if err != nil {
+		return 0, err
+	}
+
+	var size int
+
+	for _, line := range output {
+		fs := strings.Fields(line)
+		if len(fs) < 2 {
+			continue
+		}
+
+		added, errAdded := strconv.Atoi(fs[0])
+		deleted, errDeleted := strconv.Atoi(fs[1])
+
+		if errAdded != nil || errDeleted != nil {
+			// бинарный файл
+			size++
+
+			continue
+		}
+
+		size += added + deleted
+	}
+
+	return size, nil
+}
+
+func nextReviewBranch(ctx context.Context, featureBranch string) (string, error) {
+	branches, err := AllReviewBranches(ctx, featureBranch)
+	if err != nil {
+		return "", err
+	}
+
+	var maxID int
+
+	for _, branch := range branches {
+		if branch.id > maxID {
+			maxID = branch.id
+		}
+	}
+
+	return ReviewBranchName(featureBranch, maxID+1), nil
+}
@@ -0,0 +1,157 @@
+package git
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/waffleboot/giiter/internal/app"
+	"github.com/waffleboot/giiter/internal/output"
+)
+
+// Feature ветка из конфигурации вместе с дочерними feature ветками, которые на ней основаны
+type Feature struct {
+	BaseBranch string
+	BranchName string
+	Children   []*Feature
+}
+
+// FeatureTree возвращает feature ветки из конфигурации в виде дерева,
+// корни это feature ветки, основанные не на других feature ветках,
+// ветки из цикла в старой конфигурации тоже становятся корнями, чтобы не пропасть из дерева
+func FeatureTree() []*Feature {
+	nodes := make(map[string]*Feature)
+
+	for _, item := range app.Config.Persistent.FeatureBranches {
+		nodes[item.BranchName] = &Feature{
+			BaseBranch: item.BaseBranch,
+			BranchName: item.BranchName,
+		}
+	}
+
+	var roots []*Feature
+
+	for _, item := range app.Config.Persistent.FeatureBranches {
+		node := nodes[item.BranchName]
+
+		if parent, ok := nodes[item.BaseBranch]; ok && checkNoCycle(item.BaseBranch, item.BranchName) == nil {
+			parent.Children = append(parent.Children, node)
+
+			continue
+		}
+
+		roots = append(roots, node)
+	}
+
+	for _, node := range nodes {
+		sort.Slice(node.Children, func(i, j int) bool {
+			return node.Children[i].BranchName < node.Children[j].BranchName
+		})
+	}
+
+	sort.Slice(roots, func(i, j int) bool {
+		return roots[i].BranchName < roots[j].BranchName
+	})
+
+	return roots
+}
+
+// childFeatures возвращает feature ветки, основанные на featureBranch
+func childFeatures(featureBranch string) []string {
+	var children []string
+
+	for _, item := range app.Config.Persistent.FeatureBranches {
+		if item.BaseBranch == featureBranch {
+			children = append(children, item.BranchName)
+		}
+	}
+
+	sort.Strings(children)
+
+	return children
+}
+
+// StackBase возвращает ветку, на которую должен смотреть первый MR feature ветки:
+// если base ветка сама feature ветка, то это ее последняя review ветка
+func StackBase(ctx context.Context, baseBranch string) (string, error) {
+	parent := findFeatureConfig(baseBranch)
+	if parent == nil || parent.BaseBranch == "" {
+		return baseBranch, nil
+	}
+
+	records, err := State(ctx, parent.BaseBranch, parent.BranchName)
+	if err != nil {
+		return "", errors.WithMessagef(err, "parent feature %s", baseBranch)
+	}
+
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].IsOldCommit() || records[i].IsSkipped() || !records[i].HasReview() {
+			continue
+		}
+
+		return firstReviewBranch(&records[i]), nil
+	}
+
+	return baseBranch, nil
+}
+
+// restackChildren переносит дочерние feature ветки с прежней версии featureBranch на текущую
+func restackChildren(ctx context.Context, featureBranch, oldHead string) error {
+	return restackTree(ctx, featureBranch, oldHead, map[string]bool{featureBranch: true})
+}
+
+// restackTree переносит дочерние feature ветки рекурсивно, visited защищает от цикла в старой конфигурации
+func restackTree(ctx context.Context, featureBranch, oldHead string, visited map[string]bool) error {
+	children := childFeatures(featureBranch)
+	if len(children) == 0 || oldHead == "" {
+		return nil
+	}
+
+	newHead, err := revParse(ctx, featureBranch)
+	if err != nil {
+		return err
+	}
+
+	if newHead == oldHead {
+		return nil
+	}
+
+	for _, child := range children {
+		if visited[child] {
+			output.Warnf("features form a cycle at %s, fix its base branch with giiter feature add", child)
+
+			continue
+		}
+
+		visited[child] = true
+
+		childHead, err := revParse(ctx, child)
+		if err != nil {
+			return err
+		}
+
+		output.Infof("git rebase --onto %s %s %s\n", featureBranch, oldHead, child)
+
+		if _, err := run(ctx, "rebase", "--onto", featureBranch, oldHead, child); err != nil {
+			var errRun ErrRun
+			if errors.As(err, &errRun) {
+				errRun.log()
+			}
+
+			if inProgress, _ := rebaseInProgress(ctx); inProgress {
+				_, _ = run(ctx, "rebase", "--abort")
+			}
+
+			return errors.WithMessagef(err, "restack %s, rebase it manually", child)
+		}
+
+		if err := restackTree(ctx, child, childHead, visited); err != nil {
+			return err
+		}
+	}
+
+	_, err = run(ctx, "checkout", featureBranch)
+
+	return err
+}
@@ -14,59 +14,152 @@ const (
 	Prefix = "review/"
 )
 
+func ReviewBranchName(featureBranch string, id int) string {
+	return fmt.Sprintf(Prefix+"%s/%d", featureBranch, id)
+}
+
+// reviewBranchBase возвращает base ветку feature ветки, которой принадлежит review ветка,
+// пустую, если feature ветка не зарегистрирована
+func reviewBranchBase(branch string) string {
+	name := strings.TrimPrefix(branch, Prefix)
+
+	slash := strings.LastIndex(name, "/")
+	if slash < 0 {
+		return ""
+	}
+
+	feature := findFeatureConfig(name[:slash])
+	if feature == nil {
+		return ""
+	}
+
+	return feature.BaseBranch
+}
+
 type records struct {
-	records   []Record
-	shaIndex  map[string]int
-	subjIndex map[string]int
-	diffIndex map[string]int
+	records     []Record
+	shaIndex    map[string]int
+	subjIndex   map[string]int
+	diffIndex   map[string]int
+	changeIndex map[string]int
 }
 
 func State(ctx context.Context, baseBranch, featureBranch string) ([]Record, error) {
-	r, err := createRecords(ctx, baseBranch, featureBranch)
+	commits, err := featureCommits(ctx, baseBranch, featureBranch)
 	if err != nil {
 		return nil, err
 	}
 
+	r := createRecords(commits)
+
 	branches, err := AllReviewBranches(ctx, featureBranch)
 	if err != nil {
 		return nil, err
 	}
 
-	return r.matchCommitsAndBranches(ctx, branches)
+	return r.matchCommitsAndBranches(ctx, baseBranch, featureBranch, branches)
 }
 
-func createRecords(ctx context.Context, baseBranch, featureBranch string) (*records, error) {
-	commits, err := Commits(ctx, baseBranch, featureBranch)
+// filteredCommit коммит feature ветки и причина, по которой для него не нужна review ветка
+type filteredCommit struct {
+	commit     *commit
+	skipReason string
+}
+
+// featureCommits читает коммиты feature ветки от старых к новым и один раз прогоняет их через фильтры
+func featureCommits(ctx context.Context, baseBranch, featureBranch string) ([]filteredCommit, error) {
+	shas, err := allCommits(ctx, baseBranch, featureBranch)
 	if err != nil {
 		return nil, errors.WithMessage(err, "get state")
 	}
 
+	filters, err := commitFilters(baseBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := make([]filteredCommit, 0, len(shas))
+
+	for _, sha := range shas {
+		commit, err := findCommit(ctx, sha)
+		if err != nil {
+			return nil, err
+		}
+
+		reason, err := skipReason(ctx, filters, commit)
+		if err != nil {
+			return nil, err
+		}
+
+		commits = append(commits, filteredCommit{commit: commit, skipReason: reason})
+	}
+
+	return commits, nil
+}
+
+func createRecords(commits []filteredCommit) *records {
 	r := &records{
-		records:   make([]Record, 0, len(commits)),
-		shaIndex:  make(map[string]int),
-		subjIndex: make(map[string]int),
+		records:     make([]Record, 0, len(commits)),
+		shaIndex:    make(map[string]int),
+		subjIndex:   make(map[string]int),
+		changeIndex: make(map[string]int),
 	}
 
-	for i := range commits {
-		commit, errFind := findCommit(ctx, commits[i])
-		if errFind != nil {
-			return nil, errFind
+	for _, item := range commits {
+		commit := item.commit
+
+		if item.skipReason != "" {
+			r.records = append(r.records, newSkippedRecord(commit, item.skipReason))
+
+			continue
 		}
 
+		if r.joinGroup(commit) {
+			r.indexChangeID(commit, len(r.records)-1)
+
+			continue
+		}
+
+		r.indexChangeID(commit, len(r.records))
+
+		r.shaIndex[commit.SHA] = len(r.records)
+
+		r.subjIndex[commit.Message.Subject] = len(r.records)
+
 		r.records = append(r.records, newRecord(commit))
+	}
 
-		r.shaIndex[commit.SHA] = i
+	return r
+}
 
-		r.subjIndex[commit.Message.Subject] = i
+func (r *records) indexChangeID(commit *commit, index int) {
+	if !app.Config.UseChangeID {
+		return
 	}
 
-	return r, nil
+	if changeID := commit.Message.Trailer(ChangeIDTrailer); changeID != "" {
+		r.changeIndex[changeID] = index
+	}
 }
 
-func (r *records) matchCommitsAndBranches(ctx context.Context, branches []reviewBranch) ([]Record, error) {
+func (r *records) matchCommitsAndBranches(
+	ctx context.Context,
+	baseBranch, featureBranch string,
+	branches []reviewBranch,
+) ([]Record, error) {
 	for i := range branches {
 		review := branches[i]
 
+		// сначала Change-Id, он не меняется при amend и rebase коммита
+
+		if app.Config.UseChangeID {
+			if index, ok := r.changeIndex[changeIDOfBranch(featureBranch, review.BranchName())]; ok {
+				r.records[index].addReviewBranch(review)
+
+				continue
+			}
+		}
+
 		reviewSHA := review.branch.CommitSHA
 		if index, ok := r.shaIndex[reviewSHA]; ok {
 			r.records[index].addReviewBranch(review)
@@ -74,16 +167,24 @@ func (r *records) matchCommitsAndBranches(ctx context.Context, branches []review
 			continue
 		}
 
-		if errLazy := r.lazyDiffHashes(ctx); errLazy != nil {
-			return nil, errLazy
-		}
-
 		commit, err := findCommit(ctx, reviewSHA)
 		if err != nil {
 			return nil, err
 		}
 
-		diffHash, err := diffHash(ctx, reviewSHA)
+		if app.Config.UseChangeID {
+			if index, ok := r.changeIndex[commit.Message.Trailer(ChangeIDTrailer)]; ok {
+				r.records[index].addReviewBranch(review)
+
+				continue
+			}
+		}
+
+		if errLazy := r.lazyDiffHashes(ctx); errLazy != nil {
+			return nil, errLazy
+		}
+
+		diffHash, diffBase, err := reviewDiffHash(ctx, commit, baseBranch)
 		if err != nil {
 			return nil, err
 		}
@@ -104,7 +205,7 @@ func (r *records) matchCommitsAndBranches(ctx context.Context, branches []review
 			}
 		}
 
-		r.addReviewRecord(review, commit)
+		r.addReviewRecord(review, commit, diffBase)
 	}
 
 	r.fillNewCommitIDs()
@@ -112,16 +213,40 @@ func (r *records) matchCommitsAndBranches(ctx context.Context, branches []review
 	return r.records, nil
 }
 
-func (r *records) addReviewRecord(branch reviewBranch, commit *commit) {
+func (r *records) addReviewRecord(branch reviewBranch, commit *commit, diffBase string) {
 	r.shaIndex[commit.SHA] = len(r.records)
 
-	r.records = append(r.records, newReviewRecord(commit, branch))
+	record := newReviewRecord(commit, branch)
+	record.diffBase = diffBase
+
+	r.records = append(r.records, record)
+}
+
+// joinGroup присоединяет коммит к предыдущей записи если у них одна review группа
+func (r *records) joinGroup(commit *commit) bool {
+	group := commit.Message.Trailer(GroupTrailer)
+	if group == "" || len(r.records) == 0 {
+		return false
+	}
+
+	last := &r.records[len(r.records)-1]
+	if last.IsSkipped() || last.group != group {
+		return false
+	}
+
+	delete(r.shaIndex, last.featureSHA)
+
+	last.joinGroup(commit)
+
+	r.shaIndex[commit.SHA] = len(r.records) - 1
+
+	return true
 }
 
 func AllReviewBranches(ctx context.Context, featureBranch string) (result []reviewBranch, err error) {
 	branchPrefix := fmt.Sprintf(Prefix+"%s/", featureBranch)
 
-	branches, err := AllBranches(ctx, runner{})
+	branches, err := AllBranches(ctx, gitRunner)
 	if err != nil {
 		return nil, err
 	}
@@ -149,7 +274,11 @@ func (r *records) lazyDiffHashes(ctx context.Context) error {
 		r.diffIndex = make(map[string]int)
 
 		for i := range r.records {
-			diffHash, err := diffHash(ctx, r.records[i].CommitSHA())
+			if r.records[i].IsSkipped() {
+				continue
+			}
+
+			diffHash, err := recordDiffHash(ctx, &r.records[i])
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,278 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/waffleboot/giiter/internal/app"
+	"github.com/waffleboot/giiter/internal/output"
+)
+
+// RebaseReport показывает что стало с записями feature ветки после rebase на обновленную base ветку
+type RebaseReport struct {
+	// Landed записи, изменения которых уже есть в base ветке
+	Landed []Record
+	// Rebased записи, коммиты которых перенесены на base ветку
+	Rebased []Record
+	// Conflict запись, на которой rebase остановился из-за конфликта
+	Conflict *Record
+}
+
+func remoteBranch(baseBranch string) string {
+	return "origin/" + baseBranch
+}
+
+// remoteSHA возвращает коммит remote-tracking ветки, пустой если ее еще нет, например до первого fetch
+func remoteSHA(ctx context.Context, remote string) (string, error) {
+	output, err := run(ctx, "rev-parse", "--verify", "--quiet", "--short", "refs/remotes/"+remote)
+	if err == nil {
+		return output[0], nil
+	}
+
+	var errExit *exec.ExitError
+	if errors.As(err, &errExit) && errExit.ExitCode() == 1 {
+		return "", nil
+	}
+
+	return "", errors.WithMessagef(err, "resolve %s", remote)
+}
+
+// fetchBaseBranch обновляет remote-tracking ветку base и перемещает на нее локальную base ветку,
+// если локальная ветка не содержит своих коммитов
+func fetchBaseBranch(ctx context.Context, baseBranch string) error {
+	remote := remoteBranch(baseBranch)
+
+	oldRemote, err := remoteSHA(ctx, remote)
+	if err != nil {
+		return err
+	}
+
+	if _, err := run(ctx, "fetch", "origin", "+refs/heads/"+baseBranch+":refs/remotes/"+remote); err != nil {
+		return errors.WithMessagef(err, "fetch %s", baseBranch)
+	}
+
+	// локальная base ветка может отстать или указывать на старую версию base до force push,
+	// в обоих случаях ее коммиты уже были в remote ветке
+	known, err := isAncestor(ctx, baseBranch, remote)
+	if err != nil {
+		return err
+	}
+
+	if !known && oldRemote != "" {
+		known, err = isAncestor(ctx, baseBranch, oldRemote)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !known {
+		return fmt.Errorf("%s has commits not pushed to %s, update it manually", baseBranch, remote)
+	}
+
+	currentBranch, _ := getCurrentBranch(ctx)
+	if currentBranch == baseBranch {
+		return fmt.Errorf("%s is checked out, could not update it", baseBranch)
+	}
+
+	_, err = run(ctx, "branch", "-f", baseBranch, remote)
+
+	return err
+}
+
+// forkPoint ищет коммит от которого feature ветка отошла от base ветки с учетом force push base ветки,
+// если reflog remote ветки не помогает, то используется локальная base ветка до обновления
+func forkPoint(ctx context.Context, baseBranch, oldBase, featureBranch string) (string, error) {
+	output, err := run(ctx, "merge-base", "--fork-point", remoteBranch(baseBranch), featureBranch)
+	if err == nil && len(output) > 0 {
+		return output[0], nil
+	}
+
+	output, err = run(ctx, "merge-base", oldBase, featureBranch)
+	if err != nil {
+		return "", errors.WithMessage(err, "find fork point")
+	}
+
+	return output[0], nil
+}
+
+// FetchRebase обновляет base ветку из origin и перестраивает на нее feature ветку
+func FetchRebase(ctx context.Context, baseBranch, featureBranch string, resumable bool) (*RebaseReport, error) {
+	if err := checkNoPendingOperation(); err != nil {
+		return nil, err
+	}
+
+	before, err := State(ctx, baseBranch, featureBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	oldBase, err := revParse(ctx, baseBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := revParse(ctx, featureBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fetchBaseBranch(ctx, baseBranch); err != nil {
+		return nil, err
+	}
+
+	upstream, err := forkPoint(ctx, baseBranch, oldBase, featureBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	output.Infof("git rebase --onto %s %s %s\n", baseBranch, upstream, featureBranch)
+
+	op := &app.Operation{
+		BaseBranch:    baseBranch,
+		FeatureBranch: featureBranch,
+		Head:          head,
+	}
+
+	errRebase := runRebase(ctx, op, "rebase", "--onto", baseBranch, upstream, featureBranch)
+	if errRebase != nil && !errors.Is(errRebase, ErrConflict) {
+		return nil, errRebase
+	}
+
+	report := new(RebaseReport)
+
+	if errRebase != nil {
+		report.Conflict, err = conflictRecord(ctx, before)
+		if err != nil {
+			return nil, err
+		}
+
+		// после конфликта State feature ветки еще не построить, поэтому влитые записи ищет git
+		report.Landed, err = landedRecords(ctx, baseBranch, head, before)
+		if err != nil {
+			return nil, err
+		}
+
+		// после giiter continue нужно знать, что записи влиты, например чтобы снять draft с нижнего MR
+		op.Landed = len(report.Landed) > 0
+
+		if !resumable {
+			if err := Abort(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		return report, errRebase
+	}
+
+	after, err := State(ctx, baseBranch, featureBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := report.compare(ctx, before, after); err != nil {
+		return nil, err
+	}
+
+	return report, restackChildren(ctx, featureBranch, head)
+}
+
+// landedRecords возвращает записи, все коммиты которых уже есть в base ветке сами или с теми же изменениями,
+// git отмечает такие коммиты через =, а коммиты из base ветки не выводит совсем
+func landedRecords(ctx context.Context, baseBranch, head string, records []Record) ([]Record, error) {
+	output, err := run(ctx, "log", "--cherry-mark", "--right-only", "--no-merges", "--format=%m%h", baseBranch+"..."+head)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make(map[string]bool, len(output))
+
+	for _, line := range output {
+		if !strings.HasPrefix(line, "=") {
+			pending[line[1:]] = true
+		}
+	}
+
+	var result []Record
+
+	for i := range records {
+		if records[i].IsOldCommit() || records[i].IsSkipped() {
+			continue
+		}
+
+		landed := true
+
+		for _, commit := range records[i].Commits() {
+			landed = landed && !pending[commit]
+		}
+
+		if landed {
+			result = append(result, records[i])
+		}
+	}
+
+	return result, nil
+}
+
+func conflictRecord(ctx context.Context, records []Record) (*Record, error) {
+	sha, err := revParse(ctx, "REBASE_HEAD")
+	if err != nil {
+		return nil, nil //nolint:nilerr // rebase мог остановиться не на коммите
+	}
+
+	for i := range records {
+		for _, commit := range records[i].Commits() {
+			if commit == sha {
+				return &records[i], nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+func (r *RebaseReport) compare(ctx context.Context, before, after []Record) error {
+	hashes := make(map[string]bool)
+	subjects := make(map[string]bool)
+
+	for i := range after {
+		if after[i].IsOldCommit() || after[i].IsSkipped() {
+			continue
+		}
+
+		hash, err := recordDiffHash(ctx, &after[i])
+		if err != nil {
+			return err
+		}
+
+		if hash.Valid {
+			hashes[hash.String] = true
+		}
+
+		subjects[after[i].CommitMessage().Subject] = true
+	}
+
+	for i := range before {
+		if before[i].IsOldCommit() || before[i].IsSkipped() {
+			continue
+		}
+
+		hash, err := recordDiffHash(ctx, &before[i])
+		if err != nil {
+			return err
+		}
+
+		if (hash.Valid && hashes[hash.String]) || subjects[before[i].CommitMessage().Subject] {
+			r.Rebased = append(r.Rebased, before[i])
+
+			continue
+		}
+
+		r.Landed = append(r.Landed, before[i])
+	}
+
+	return nil
+}
@@ -2,37 +2,227 @@ package gitlab
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
+	"strconv"
 	"strings"
 )
 
-const baseURL = "https://gitlab.com/api/v4/projects/34829333/merge_requests"
-
+// MergeRequest новый MR, пользователи и milestone задаются id, см. UserID и MilestoneID
 type MergeRequest struct {
-	Title        string
-	SourceBranch string
-	TargetBranch string
+	Title              string
+	SourceBranch       string
+	TargetBranch       string
+	Description        string
+	Labels             []string
+	AssigneeIDs        []int
+	ReviewerIDs        []int
+	MilestoneID        int
+	RemoveSourceBranch bool
+	Squash             bool
+}
+
+// Client клиент GitLab API v4 для одного проекта
+type Client struct {
+	baseURL string
+	project string
+	token   string
 }
 
-func CreateMergeRequest(ctx context.Context, token string, mr MergeRequest) error {
+// NewClient создает клиент, apiURL вида https://gitlab.com/api/v4, project это id или путь group/project
+func NewClient(apiURL, project, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimSuffix(apiURL, "/"),
+		project: project,
+		token:   token,
+	}
+}
+
+func (c *Client) projectURL(path string) string {
+	return c.baseURL + "/projects/" + url.PathEscape(c.project) + path
+}
+
+func (c *Client) CreateMergeRequest(ctx context.Context, mr MergeRequest) error {
 	data := url.Values{
-		"id":            {"34829333"},
 		"title":         {mr.Title},
 		"source_branch": {mr.SourceBranch},
 		"target_branch": {mr.TargetBranch},
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL, strings.NewReader(data.Encode()))
+	if mr.Description != "" {
+		data.Set("description", mr.Description)
+	}
+
+	if len(mr.Labels) > 0 {
+		data.Set("labels", strings.Join(mr.Labels, ","))
+	}
+
+	addIDs(data, "assignee_ids[]", mr.AssigneeIDs)
+	addIDs(data, "reviewer_ids[]", mr.ReviewerIDs)
+
+	if mr.MilestoneID != 0 {
+		data.Set("milestone_id", strconv.Itoa(mr.MilestoneID))
+	}
+
+	if mr.RemoveSourceBranch {
+		data.Set("remove_source_branch", "true")
+	}
+
+	if mr.Squash {
+		data.Set("squash", "true")
+	}
+
+	return c.do(ctx, http.MethodPost, c.projectURL("/merge_requests"), data, nil)
+}
+
+func addIDs(data url.Values, key string, ids []int) {
+	for _, id := range ids {
+		data.Add(key, strconv.Itoa(id))
+	}
+}
+
+// UserID возвращает id пользователя по имени
+func (c *Client) UserID(ctx context.Context, username string) (int, error) {
+	var users []struct {
+		ID int `json:"id"`
+	}
+
+	if err := c.do(ctx, http.MethodGet, c.baseURL+"/users?username="+url.QueryEscape(username), nil, &users); err != nil {
+		return 0, err
+	}
+
+	if len(users) == 0 {
+		return 0, fmt.Errorf("gitlab user %s not found", username)
+	}
+
+	return users[0].ID, nil
+}
+
+// UserIDByEmail возвращает id пользователя с таким публичным адресом, 0 если такого пользователя нет
+func (c *Client) UserIDByEmail(ctx context.Context, email string) (int, error) {
+	var users []struct {
+		ID          int    `json:"id"`
+		PublicEmail string `json:"public_email"`
+	}
+
+	if err := c.do(ctx, http.MethodGet, c.baseURL+"/users?search="+url.QueryEscape(email), nil, &users); err != nil {
+		return 0, err
+	}
+
+	// search ищет и по части имени, поэтому адрес сверяется
+	for _, user := range users {
+		if strings.EqualFold(user.PublicEmail, email) {
+			return user.ID, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// MilestoneID возвращает id milestone проекта по названию
+func (c *Client) MilestoneID(ctx context.Context, title string) (int, error) {
+	var milestones []struct {
+		ID int `json:"id"`
+	}
+
+	if err := c.do(ctx, http.MethodGet, c.projectURL("/milestones?title="+url.QueryEscape(title)), nil, &milestones); err != nil {
+		return 0, err
+	}
+
+	if len(milestones) == 0 {
+		return 0, fmt.Errorf("gitlab milestone %s not found", title)
+	}
+
+	return milestones[0].ID, nil
+}
+
+// OpenMergeRequest открытый MR
+type OpenMergeRequest struct {
+	IID          int    `json:"iid"`
+	Title        string `json:"title"`
+	TargetBranch string `json:"target_branch"`
+}
+
+// FindMergeRequest возвращает открытый MR из ветки, nil если такого MR нет
+func (c *Client) FindMergeRequest(ctx context.Context, sourceBranch string) (*OpenMergeRequest, error) {
+	query := url.Values{
+		"state":         {"opened"},
+		"source_branch": {sourceBranch},
+	}
+
+	var mrs []OpenMergeRequest
+
+	if err := c.do(ctx, http.MethodGet, c.projectURL("/merge_requests?"+query.Encode()), nil, &mrs); err != nil {
+		return nil, err
+	}
+
+	if len(mrs) == 0 {
+		return nil, nil
+	}
+
+	return &mrs[0], nil
+}
+
+// UpdateTitle меняет заголовок MR, через заголовок GitLab включает и выключает draft
+func (c *Client) UpdateTitle(ctx context.Context, iid int, title string) error {
+	data := url.Values{
+		"title": {title},
+	}
+
+	return c.do(ctx, http.MethodPut, c.projectURL(fmt.Sprintf("/merge_requests/%d", iid)), data, nil)
+}
+
+// UpdateTarget меняет целевую ветку MR
+func (c *Client) UpdateTarget(ctx context.Context, iid int, target string) error {
+	data := url.Values{
+		"target_branch": {target},
+	}
+
+	return c.do(ctx, http.MethodPut, c.projectURL(fmt.Sprintf("/merge_requests/%d", iid)), data, nil)
+}
+
+// UpdateReview назначает ревьюеров MR и включает squash, их нельзя задать через push options
+func (c *Client) UpdateReview(ctx context.Context, iid int, reviewerIDs []int, squash bool) error {
+	data := url.Values{}
+
+	addIDs(data, "reviewer_ids[]", reviewerIDs)
+
+	if squash {
+		data.Set("squash", "true")
+	}
+
+	return c.do(ctx, http.MethodPut, c.projectURL(fmt.Sprintf("/merge_requests/%d", iid)), data, nil)
+}
+
+// CreateNote добавляет комментарий в MR, body в markdown
+func (c *Client) CreateNote(ctx context.Context, iid int, body string) error {
+	data := url.Values{
+		"body": {body},
+	}
+
+	return c.do(ctx, http.MethodPost, c.projectURL(fmt.Sprintf("/merge_requests/%d/notes", iid)), data, nil)
+}
+
+// do выполняет запрос, data отправляется как форма, ответ разбирается в result, если он не nil
+func (c *Client) do(ctx context.Context, method, rawURL string, data url.Values, result interface{}) error {
+	var body io.Reader
+	if data != nil {
+		body = strings.NewReader(data.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
 	if err != nil {
 		return err
 	}
 
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", "Bearer "+token)
+	if data != nil {
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	req.Header.Add("Authorization", "Bearer "+c.token)
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
@@ -41,11 +231,43 @@ func CreateMergeRequest(ctx context.Context, token string, mr MergeRequest) erro
 
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusCreated {
-		_, _ = io.Copy(os.Stdout, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+		return fmt.Errorf("gitlab %s %s: %s %s", method, req.URL.Path, resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// draftPrefixes префиксы заголовка, по которым GitLab считает MR черновиком, WIP с GitLab 16 не считается
+var draftPrefixes = []string{"draft:", "[draft]", "(draft)"}
+
+// IsDraft проверяет, отмечен ли MR с таким заголовком как черновик
+func IsDraft(title string) bool {
+	return stripDraft(title) != title
+}
+
+// DraftTitle возвращает заголовок черновика или готового MR
+func DraftTitle(title string, draft bool) string {
+	title = stripDraft(title)
+	if draft {
+		return "Draft: " + title
+	}
+
+	return title
+}
 
-		return fmt.Errorf("%d", resp.StatusCode)
+func stripDraft(title string) string {
+	for _, prefix := range draftPrefixes {
+		if len(title) >= len(prefix) && strings.EqualFold(title[:len(prefix)], prefix) {
+			return stripDraft(strings.TrimLeft(title[len(prefix):], " "))
+		}
 	}
 
-	return nil
+	return title
 }
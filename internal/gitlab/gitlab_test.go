@@ -0,0 +1,231 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDraftTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		draft bool
+		want  string
+	}{
+		{title: "parser: add lexer", draft: true, want: "Draft: parser: add lexer"},
+		{title: "parser: add lexer", draft: false, want: "parser: add lexer"},
+		{title: "Draft: parser: add lexer", draft: false, want: "parser: add lexer"},
+		{title: "Draft: parser: add lexer", draft: true, want: "Draft: parser: add lexer"},
+		{title: "[draft] Draft: fix", draft: false, want: "fix"},
+		{title: "WIP: fix", draft: false, want: "WIP: fix"},
+		{title: "[WIP] fix", draft: true, want: "Draft: [WIP] fix"},
+		{title: "(Draft) fix", draft: true, want: "Draft: fix"},
+		{title: "Drafting notes", draft: false, want: "Drafting notes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			require.Equal(t, tt.want, DraftTitle(tt.title, tt.draft))
+			require.Equal(t, tt.draft, IsDraft(DraftTitle(tt.title, tt.draft)))
+		})
+	}
+}
+
+// request запрос, который получил тестовый сервер GitLab
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	form   url.Values
+}
+
+// newTestClient возвращает клиент тестового сервера, который отвечает reply и запоминает запрос
+func newTestClient(t *testing.T, status int, reply string) (*Client, *request) {
+	var got request
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		require.NoError(t, r.ParseForm())
+
+		got = request{method: r.Method, path: r.URL.EscapedPath(), query: r.URL.Query(), form: r.PostForm}
+
+		w.WriteHeader(status)
+		fmt.Fprint(w, reply)
+	}))
+	t.Cleanup(server.Close)
+
+	return NewClient(server.URL+"/api/v4/", "group/project", "secret"), &got
+}
+
+func TestCreateMergeRequest(t *testing.T) {
+	client, got := newTestClient(t, http.StatusCreated, `{"iid": 1}`)
+
+	err := client.CreateMergeRequest(context.Background(), MergeRequest{
+		Title:              "parser",
+		SourceBranch:       "review/fa/1",
+		TargetBranch:       "master",
+		Description:        "Lexer",
+		Labels:             []string{"backend", "parser"},
+		AssigneeIDs:        []int{1},
+		ReviewerIDs:        []int{2, 3},
+		MilestoneID:        4,
+		RemoveSourceBranch: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.MethodPost, got.method)
+	require.Equal(t, "/api/v4/projects/group%2Fproject/merge_requests", got.path)
+	require.Equal(t, url.Values{
+		"title":                {"parser"},
+		"source_branch":        {"review/fa/1"},
+		"target_branch":        {"master"},
+		"description":          {"Lexer"},
+		"labels":               {"backend,parser"},
+		"assignee_ids[]":       {"1"},
+		"reviewer_ids[]":       {"2", "3"},
+		"milestone_id":         {"4"},
+		"remove_source_branch": {"true"},
+	}, got.form)
+}
+
+func TestCreateMergeRequestError(t *testing.T) {
+	client, _ := newTestClient(t, http.StatusConflict, `{"message": "already exists"}`)
+
+	err := client.CreateMergeRequest(context.Background(), MergeRequest{Title: "parser"})
+	require.EqualError(t, err,
+		`gitlab POST /api/v4/projects/group/project/merge_requests: 409 Conflict {"message": "already exists"}`)
+}
+
+func TestFindMergeRequest(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		client, got := newTestClient(t, http.StatusOK, `[{"iid": 7, "title": "Draft: parser", "target_branch": "master"}]`)
+
+		mr, err := client.FindMergeRequest(context.Background(), "review/fa/1")
+		require.NoError(t, err)
+		require.Equal(t, &OpenMergeRequest{IID: 7, Title: "Draft: parser", TargetBranch: "master"}, mr)
+		require.Equal(t, http.MethodGet, got.method)
+		require.Equal(t, "/api/v4/projects/group%2Fproject/merge_requests", got.path)
+		require.Equal(t, url.Values{"state": {"opened"}, "source_branch": {"review/fa/1"}}, got.query)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		client, _ := newTestClient(t, http.StatusOK, `[]`)
+
+		mr, err := client.FindMergeRequest(context.Background(), "review/fa/1")
+		require.NoError(t, err)
+		require.Nil(t, mr)
+	})
+}
+
+func TestUserID(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		client, got := newTestClient(t, http.StatusOK, `[{"id": 2}]`)
+
+		id, err := client.UserID(context.Background(), "bob")
+		require.NoError(t, err)
+		require.Equal(t, 2, id)
+		require.Equal(t, "/api/v4/users", got.path)
+		require.Equal(t, url.Values{"username": {"bob"}}, got.query)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		client, _ := newTestClient(t, http.StatusOK, `[]`)
+
+		_, err := client.UserID(context.Background(), "bob")
+		require.EqualError(t, err, "gitlab user bob not found")
+	})
+}
+
+func TestUserIDByEmail(t *testing.T) {
+	client, got := newTestClient(t, http.StatusOK,
+		`[{"id": 5, "public_email": "carol.smith@example.com"}, {"id": 3, "public_email": "Carol@example.com"}]`)
+
+	id, err := client.UserIDByEmail(context.Background(), "carol@example.com")
+	require.NoError(t, err)
+	require.Equal(t, 3, id)
+	require.Equal(t, url.Values{"search": {"carol@example.com"}}, got.query)
+
+	id, err = client.UserIDByEmail(context.Background(), "erin@example.com")
+	require.NoError(t, err)
+	require.Zero(t, id)
+}
+
+func TestMilestoneID(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		client, got := newTestClient(t, http.StatusOK, `[{"id": 4}]`)
+
+		id, err := client.MilestoneID(context.Background(), "v 2")
+		require.NoError(t, err)
+		require.Equal(t, 4, id)
+		require.Equal(t, "/api/v4/projects/group%2Fproject/milestones", got.path)
+		require.Equal(t, url.Values{"title": {"v 2"}}, got.query)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		client, _ := newTestClient(t, http.StatusOK, `[]`)
+
+		_, err := client.MilestoneID(context.Background(), "v2")
+		require.EqualError(t, err, "gitlab milestone v2 not found")
+	})
+}
+
+func TestUpdateMergeRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		update func(*Client) error
+		method string
+		path   string
+		form   url.Values
+	}{
+		{
+			name:   "title",
+			update: func(c *Client) error { return c.UpdateTitle(context.Background(), 7, "parser") },
+			method: http.MethodPut,
+			path:   "/api/v4/projects/group%2Fproject/merge_requests/7",
+			form:   url.Values{"title": {"parser"}},
+		},
+		{
+			name:   "target",
+			update: func(c *Client) error { return c.UpdateTarget(context.Background(), 7, "review/fa/1") },
+			method: http.MethodPut,
+			path:   "/api/v4/projects/group%2Fproject/merge_requests/7",
+			form:   url.Values{"target_branch": {"review/fa/1"}},
+		},
+		{
+			name:   "review",
+			update: func(c *Client) error { return c.UpdateReview(context.Background(), 7, []int{2, 3}, true) },
+			method: http.MethodPut,
+			path:   "/api/v4/projects/group%2Fproject/merge_requests/7",
+			form:   url.Values{"reviewer_ids[]": {"2", "3"}, "squash": {"true"}},
+		},
+		{
+			name:   "review without squash",
+			update: func(c *Client) error { return c.UpdateReview(context.Background(), 7, []int{2}, false) },
+			method: http.MethodPut,
+			path:   "/api/v4/projects/group%2Fproject/merge_requests/7",
+			form:   url.Values{"reviewer_ids[]": {"2"}},
+		},
+		{
+			name:   "note",
+			update: func(c *Client) error { return c.CreateNote(context.Background(), 7, "**rebased**") },
+			method: http.MethodPost,
+			path:   "/api/v4/projects/group%2Fproject/merge_requests/7/notes",
+			form:   url.Values{"body": {"**rebased**"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, got := newTestClient(t, http.StatusOK, `{}`)
+
+			require.NoError(t, tt.update(client))
+			require.Equal(t, tt.method, got.method)
+			require.Equal(t, tt.path, got.path)
+			require.Equal(t, tt.form, got.form)
+		})
+	}
+}
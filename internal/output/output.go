@@ -0,0 +1,232 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/waffleboot/giiter/internal/app"
+)
+
+// Режимы цвета
+const (
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+const reset = "\033[0m"
+
+var palette = map[string]string{
+	"black":   "\033[30m",
+	"red":     "\033[31m",
+	"green":   "\033[32m",
+	"yellow":  "\033[33m",
+	"blue":    "\033[34m",
+	"magenta": "\033[35m",
+	"cyan":    "\033[36m",
+	"white":   "\033[37m",
+	"grey":    "\033[90m",
+	"bold":    "\033[1m",
+	"none":    "",
+}
+
+// writer весь вывод команд giiter
+var writer io.Writer = os.Stdout
+
+// errWriter сообщения о ходе работы, предупреждения и отладка, они идут в stderr,
+// чтобы не смешиваться с результатом команды, например с list -o json
+var errWriter io.Writer = &lockedWriter{w: os.Stderr}
+
+// lockedWriter не дает перемешаться строкам, которые пишут одновременно git и giiter
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.w.Write(p)
+}
+
+// colored выводить ли цвета
+var colored bool
+
+// Setup выбирает режим цвета: auto включает цвета только для терминала и без NO_COLOR,
+// ошибки настроек не мешают работе, о них только предупреждаем
+func Setup() {
+	for role, color := range app.Config.Colors {
+		if err := checkColor(color); err != nil {
+			Warnf("color.%s: %v", role, err)
+		}
+	}
+
+	if err := checkMode(app.Config.Color); err != nil {
+		Warnf("%v", err)
+	}
+
+	colored = useColor(app.Config.Color, os.Getenv("NO_COLOR") != "", isTerminal(os.Stdout))
+}
+
+// useColor решает, выводить ли цвета, неизвестный режим работает как auto
+func useColor(mode string, noColor, terminal bool) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	default:
+		return !noColor && terminal
+	}
+}
+
+func checkColor(color string) error {
+	if _, ok := palette[color]; !ok {
+		return fmt.Errorf("unknown color %s", color)
+	}
+
+	return nil
+}
+
+func checkMode(mode string) error {
+	switch mode {
+	case ColorAuto, ColorAlways, ColorNever:
+		return nil
+	default:
+		return fmt.Errorf("unknown color mode %s, use %s, %s or %s", mode, ColorAuto, ColorAlways, ColorNever)
+	}
+}
+
+// CheckSetting проверяет значение настройки вывода перед записью в конфигурацию
+func CheckSetting(key, value string) error {
+	switch {
+	case key == "color":
+		return checkMode(value)
+	case strings.HasPrefix(key, "color."):
+		return checkColor(value)
+	default:
+		return nil
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Paint раскрашивает текст цветом элемента role из темы
+func Paint(role, text string) string {
+	if !colored {
+		return text
+	}
+
+	code := palette[app.Config.Colors[role]]
+	if code == "" {
+		return text
+	}
+
+	return code + text + reset
+}
+
+// Marker возвращает раскрашенную метку статуса записи
+func Marker(status string) string {
+	return Paint(status, app.Config.Markers[status])
+}
+
+// GitColor возвращает флаг цвета для git, чтобы diff следовал режиму giiter
+func GitColor() string {
+	if colored {
+		return "--color=always"
+	}
+
+	return "--color=never"
+}
+
+// ErrWriter возвращает вывод сообщений, чтобы stderr git шел туда же
+func ErrWriter() io.Writer {
+	return errWriter
+}
+
+// Writer возвращает текущий вывод, например pager, чтобы git писал туда же
+func Writer() io.Writer {
+	return writer
+}
+
+// StartPager направляет вывод в pager, если stdout терминал, и возвращает функцию,
+// которая закрывает pager и ждет, пока его закроет пользователь
+func StartPager(pager string) (func(), error) {
+	if pager == "" || pager == "cat" || !isTerminal(os.Stdout) {
+		return func() {}, nil
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("sh", "-c", pager)
+	cmd.Stdin = r
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = errWriter
+	cmd.Env = pagerEnv()
+
+	if err := cmd.Start(); err != nil {
+		r.Close()
+		w.Close()
+
+		return nil, fmt.Errorf("pager %s: %w", pager, err)
+	}
+
+	r.Close()
+
+	writer = w
+
+	return func() {
+		w.Close()
+		_ = cmd.Wait()
+
+		writer = os.Stdout
+	}, nil
+}
+
+// pagerEnv как и git по умолчанию просит less и lv пропускать цвета
+func pagerEnv() []string {
+	env := os.Environ()
+
+	if os.Getenv("LESS") == "" {
+		env = append(env, "LESS=FRX")
+	}
+
+	if os.Getenv("LV") == "" {
+		env = append(env, "LV=-c")
+	}
+
+	return env
+}
+
+func Printf(format string, args ...interface{}) {
+	fmt.Fprintf(writer, format, args...)
+}
+
+func Println(args ...interface{}) {
+	fmt.Fprintln(writer, args...)
+}
+
+// Infof выводит сообщение о ходе работы команды
+func Infof(format string, args ...interface{}) {
+	fmt.Fprintf(errWriter, format, args...)
+}
+
+// Warnf выводит предупреждение, команда при этом продолжает работу
+func Warnf(format string, args ...interface{}) {
+	fmt.Fprintf(errWriter, "warning: "+format+"\n", args...)
+}
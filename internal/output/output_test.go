@@ -0,0 +1,135 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/waffleboot/giiter/internal/app"
+)
+
+// capture направляет вывод и сообщения в буферы до конца теста, там же восстанавливаются настройки
+func capture(t *testing.T) (out, errOut *bytes.Buffer) {
+	savedWriter, savedErrWriter, savedColored, savedConfig := writer, errWriter, colored, app.Config
+
+	out, errOut = new(bytes.Buffer), new(bytes.Buffer)
+	writer, errWriter = out, errOut
+
+	app.Config.Colors = map[string]string{"ok": "green", "old": "red", "skip": "none"}
+	app.Config.Markers = map[string]string{"ok": "ok", "old": "--", "skip": "skip"}
+
+	t.Cleanup(func() {
+		writer, errWriter, colored, app.Config = savedWriter, savedErrWriter, savedColored, savedConfig
+	})
+
+	return out, errOut
+}
+
+func TestUseColor(t *testing.T) {
+	tests := []struct {
+		mode     string
+		noColor  bool
+		terminal bool
+		want     bool
+	}{
+		{mode: ColorAuto, terminal: true, want: true},
+		{mode: ColorAuto, terminal: true, noColor: true},
+		{mode: ColorAuto},
+		{mode: ColorAlways, want: true},
+		{mode: ColorAlways, noColor: true, want: true},
+		{mode: ColorNever, terminal: true},
+		{mode: "sometimes", terminal: true, want: true},
+		{mode: "sometimes"},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, useColor(tt.mode, tt.noColor, tt.terminal),
+			"mode %s, NO_COLOR %v, terminal %v", tt.mode, tt.noColor, tt.terminal)
+	}
+}
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		colors   map[string]string
+		colored  bool
+		gitColor string
+		warning  string
+	}{
+		{name: "always", mode: ColorAlways, colored: true, gitColor: "--color=always"},
+		{name: "never", mode: ColorNever, gitColor: "--color=never"},
+		// stdout теста не терминал
+		{name: "auto", mode: ColorAuto, gitColor: "--color=never"},
+		{
+			name:     "unknown mode",
+			mode:     "sometimes",
+			gitColor: "--color=never",
+			warning:  "warning: unknown color mode sometimes, use auto, always or never\n",
+		},
+		{
+			name:     "unknown color",
+			mode:     ColorNever,
+			colors:   map[string]string{"ok": "pink"},
+			gitColor: "--color=never",
+			warning:  "warning: color.ok: unknown color pink\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, errOut := capture(t)
+			app.Config.Color = tt.mode
+
+			if tt.colors != nil {
+				app.Config.Colors = tt.colors
+			}
+
+			Setup()
+			require.Equal(t, tt.colored, colored)
+			require.Equal(t, tt.gitColor, GitColor())
+			require.Equal(t, tt.warning, errOut.String())
+			require.Empty(t, out.String())
+		})
+	}
+}
+
+func TestPaint(t *testing.T) {
+	capture(t)
+
+	colored = false
+	require.Equal(t, "ok", Marker("ok"))
+
+	colored = true
+	require.Equal(t, "\033[32mok\033[0m", Marker("ok"))
+	require.Equal(t, "\033[31m--\033[0m", Marker("old"))
+	require.Equal(t, "skip", Marker("skip"))
+	require.Equal(t, "text", Paint("unknown", "text"))
+
+	app.Config.Markers["ok"] = "done"
+	app.Config.Colors["ok"] = "bold"
+	require.Equal(t, "\033[1mdone\033[0m", Marker("ok"))
+}
+
+func TestStderrRouting(t *testing.T) {
+	out, errOut := capture(t)
+
+	Printf("%d records\n", 2)
+	Println("done")
+	Infof("git %s\n", "push")
+	Warnf("skip %s", "fa")
+
+	require.Equal(t, "2 records\ndone\n", out.String())
+	require.Equal(t, "git push\nwarning: skip fa\n", errOut.String())
+	require.Equal(t, out, Writer())
+	require.Equal(t, errOut, ErrWriter())
+}
+
+func TestCheckSetting(t *testing.T) {
+	require.NoError(t, CheckSetting("color", ColorAlways))
+	require.EqualError(t, CheckSetting("color", "yes"), "unknown color mode yes, use auto, always or never")
+	require.NoError(t, CheckSetting("color.ok", "cyan"))
+	require.EqualError(t, CheckSetting("color.ok", "pink"), "unknown color pink")
+	require.NoError(t, CheckSetting("marker.ok", "anything"))
+}
@@ -0,0 +1,71 @@
+package tui
+
+// Key нажатая клавиша, печатные клавиши представлены своим символом
+type Key string
+
+const (
+	KeyUp       Key = "up"
+	KeyDown     Key = "down"
+	KeyPageUp   Key = "pgup"
+	KeyPageDown Key = "pgdown"
+	KeyEnter    Key = "enter"
+	KeyEscape   Key = "esc"
+)
+
+// escapeKeys escape последовательности терминала
+var escapeKeys = map[string]Key{
+	"\x1b[A":  KeyUp,
+	"\x1b[B":  KeyDown,
+	"\x1bOA":  KeyUp,
+	"\x1bOB":  KeyDown,
+	"\x1b[5~": KeyPageUp,
+	"\x1b[6~": KeyPageDown,
+}
+
+// ParseKeys разбирает прочитанные из терминала байты на клавиши,
+// неизвестные escape последовательности пропускаются
+func ParseKeys(buf []byte) []Key {
+	var keys []Key
+
+	for i := 0; i < len(buf); {
+		switch buf[i] {
+		case '\r', '\n':
+			keys = append(keys, KeyEnter)
+			i++
+		case 0x03:
+			// Ctrl-C в raw режиме не присылает сигнал
+			keys = append(keys, "q")
+			i++
+		case 0x1b:
+			n := escapeLength(buf[i:])
+			if n == 1 {
+				keys = append(keys, KeyEscape)
+			} else if key, ok := escapeKeys[string(buf[i:i+n])]; ok {
+				keys = append(keys, key)
+			}
+
+			i += n
+		default:
+			r := []rune(string(buf[i:]))[0]
+			keys = append(keys, Key(string(r)))
+			i += len(string(r))
+		}
+	}
+
+	return keys
+}
+
+// escapeLength возвращает длину escape последовательности в начале buf
+func escapeLength(buf []byte) int {
+	if len(buf) < 2 || (buf[1] != '[' && buf[1] != 'O') {
+		return 1
+	}
+
+	for i := 2; i < len(buf); i++ {
+		if buf[i] >= 0x40 && buf[i] <= 0x7e {
+			return i + 1
+		}
+	}
+
+	return len(buf)
+}
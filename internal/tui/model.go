@@ -0,0 +1,370 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Row строка списка записей feature ветки
+type Row struct {
+	Marker    string
+	SHA       string
+	Branches  string
+	Subject   string
+	HasReview bool
+	Skipped   bool
+}
+
+func (r Row) text(position int) string {
+	text := fmt.Sprintf("%d) %s %s", position, r.Marker, r.SHA)
+	if r.Branches != "" {
+		text += " [" + r.Branches + "]"
+	}
+
+	return text + " " + r.Subject
+}
+
+// Mode режим ввода модели
+type Mode int
+
+const (
+	ModeBrowse Mode = iota
+	ModeAssign
+	ModeMove
+	ModeDrop
+)
+
+// ActionKind действие, которое модель просит выполнить
+type ActionKind int
+
+const (
+	ActionNone ActionKind = iota
+	ActionQuit
+	ActionPreview
+	ActionAssign
+	ActionMove
+	ActionDrop
+	ActionOpen
+	ActionMake
+	ActionRefresh
+)
+
+// Action действие над записями, позиции начинаются с 1
+type Action struct {
+	Kind ActionKind
+	From int
+	To   int
+}
+
+// Model состояние интерфейса без терминала: список записей, выбранная запись, режим и просмотр diff
+type Model struct {
+	rows    []Row
+	cursor  int
+	mode    Mode
+	marked  int
+	status  string
+	preview []string
+	scroll  int
+}
+
+func NewModel(rows []Row) *Model {
+	return &Model{rows: rows}
+}
+
+// SetRows обновляет записи после действия, выбранная позиция сохраняется
+func (m *Model) SetRows(rows []Row) {
+	m.rows = rows
+
+	if m.cursor >= len(rows) {
+		m.cursor = len(rows) - 1
+	}
+
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+}
+
+// Selected возвращает позицию выбранной записи, 0 если записей нет
+func (m *Model) Selected() int {
+	if len(m.rows) == 0 {
+		return 0
+	}
+
+	return m.cursor + 1
+}
+
+func (m *Model) Mode() Mode {
+	return m.mode
+}
+
+func (m *Model) Status() string {
+	return m.status
+}
+
+func (m *Model) SetStatus(status string) {
+	m.status = status
+}
+
+// SetPreview показывает diff выбранной записи
+func (m *Model) SetPreview(lines []string) {
+	m.preview = lines
+	m.scroll = 0
+}
+
+// HandleKey обрабатывает нажатие клавиши и возвращает действие для выполнения
+func (m *Model) HandleKey(key Key) Action {
+	m.status = ""
+
+	switch key {
+	case KeyUp, "k":
+		return m.moveCursor(-1)
+	case KeyDown, "j":
+		return m.moveCursor(1)
+	case KeyPageDown, " ":
+		m.scroll += 10
+		if m.scroll > len(m.preview)-1 {
+			m.scroll = max(len(m.preview)-1, 0)
+		}
+
+		return Action{}
+	case KeyPageUp, "b":
+		m.scroll = max(m.scroll-10, 0)
+
+		return Action{}
+	case KeyEscape:
+		m.mode = ModeBrowse
+
+		return Action{}
+	}
+
+	switch m.mode {
+	case ModeAssign:
+		return m.finishAssign(key)
+	case ModeMove:
+		return m.finishMove(key)
+	case ModeDrop:
+		return m.finishDrop(key)
+	default:
+		return m.browse(key)
+	}
+}
+
+func (m *Model) moveCursor(delta int) Action {
+	cursor := m.cursor + delta
+	if cursor < 0 || cursor >= len(m.rows) {
+		return Action{}
+	}
+
+	m.cursor = cursor
+
+	if m.mode != ModeBrowse {
+		return Action{}
+	}
+
+	return Action{Kind: ActionPreview, From: m.Selected()}
+}
+
+func (m *Model) browse(key Key) Action {
+	if key == "q" {
+		return Action{Kind: ActionQuit}
+	}
+
+	if len(m.rows) == 0 {
+		return Action{}
+	}
+
+	row := m.rows[m.cursor]
+
+	switch key {
+	case "a":
+		if row.Skipped || row.HasReview {
+			m.status = "select a new commit to assign"
+
+			return Action{}
+		}
+
+		m.startMode(ModeAssign, "choose review branch for the commit and press enter")
+	case "m":
+		m.startMode(ModeMove, "choose new position and press enter")
+	case "d":
+		m.startMode(ModeDrop, fmt.Sprintf("drop %s %s? (y/n)", row.SHA, row.Subject))
+	case "o":
+		if !row.HasReview {
+			m.status = "record has no review branch"
+
+			return Action{}
+		}
+
+		return Action{Kind: ActionOpen, From: m.Selected()}
+	case "M":
+		return Action{Kind: ActionMake}
+	case "r":
+		return Action{Kind: ActionRefresh}
+	case KeyEnter:
+		return Action{Kind: ActionPreview, From: m.Selected()}
+	}
+
+	return Action{}
+}
+
+func (m *Model) startMode(mode Mode, status string) {
+	m.mode = mode
+	m.marked = m.Selected()
+	m.status = status
+}
+
+func (m *Model) finishAssign(key Key) Action {
+	if key != KeyEnter {
+		return Action{}
+	}
+
+	if m.Selected() == m.marked || !m.rows[m.cursor].HasReview {
+		m.status = "choose a record with review branch"
+
+		return Action{}
+	}
+
+	m.mode = ModeBrowse
+
+	return Action{Kind: ActionAssign, From: m.marked, To: m.Selected()}
+}
+
+func (m *Model) finishMove(key Key) Action {
+	if key != KeyEnter {
+		return Action{}
+	}
+
+	m.mode = ModeBrowse
+
+	if m.Selected() == m.marked {
+		return Action{}
+	}
+
+	return Action{Kind: ActionMove, From: m.marked, To: m.Selected()}
+}
+
+func (m *Model) finishDrop(key Key) Action {
+	m.mode = ModeBrowse
+
+	if key != "y" {
+		return Action{}
+	}
+
+	return Action{Kind: ActionDrop, From: m.marked}
+}
+
+// View возвращает экран: список записей, diff выбранной записи и строку статуса
+func (m *Model) View(width, height int) []string {
+	if height < 3 {
+		height = 3
+	}
+
+	listHeight := min(len(m.rows), height/2)
+	if listHeight == 0 {
+		listHeight = 1
+	}
+
+	lines := make([]string, 0, height)
+
+	first := 0
+	if m.cursor >= listHeight {
+		first = m.cursor - listHeight + 1
+	}
+
+	for i := first; i < first+listHeight; i++ {
+		if i >= len(m.rows) {
+			lines = append(lines, "no records")
+
+			break
+		}
+
+		prefix := "  "
+
+		switch {
+		case i == m.cursor:
+			prefix = "> "
+		case m.mode != ModeBrowse && i+1 == m.marked:
+			prefix = "* "
+		}
+
+		lines = append(lines, clip(prefix+m.rows[i].text(i+1), width))
+	}
+
+	lines = append(lines, strings.Repeat("-", width))
+
+	previewHeight := height - len(lines) - 1
+
+	for i := m.scroll; i < len(m.preview) && previewHeight > 0; i++ {
+		lines = append(lines, clip(m.preview[i], width))
+		previewHeight--
+	}
+
+	for ; previewHeight > 0; previewHeight-- {
+		lines = append(lines, "")
+	}
+
+	status := m.status
+	if status == "" {
+		status = "j/k move  a assign  m move  d drop  o open MR  M make  r refresh  q quit"
+	}
+
+	return append(lines, clip(status, width))
+}
+
+// clip обрезает строку по ширине экрана, цветовые escape последовательности ширины не занимают
+func clip(line string, width int) string {
+	line = strings.ReplaceAll(line, "\t", "    ")
+
+	if width <= 0 {
+		return line
+	}
+
+	var (
+		visible int
+		colored bool
+	)
+
+	runes := []rune(line)
+
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '\033' {
+			start := i
+
+			for i < len(runes) && runes[i] != 'm' {
+				i++
+			}
+
+			colored = i < len(runes) && string(runes[start:i+1]) != "\033[0m"
+
+			continue
+		}
+
+		if visible == width {
+			if colored {
+				return string(runes[:i]) + "\033[0m"
+			}
+
+			return string(runes[:i])
+		}
+
+		visible++
+	}
+
+	return line
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
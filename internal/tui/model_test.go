@@ -0,0 +1,123 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testRows() []Row {
+	return []Row{
+		{Marker: "ok", SHA: "1111111", Branches: "fa/1", Subject: "first", HasReview: true},
+		{Marker: "**", SHA: "2222222", Branches: "fa/2", Subject: "second", HasReview: true},
+		{Marker: "++", SHA: "3333333", Subject: "third"},
+		{Marker: "skip", SHA: "4444444", Subject: "fixup! first", Skipped: true},
+	}
+}
+
+func press(m *Model, keys ...Key) Action {
+	var action Action
+
+	for _, key := range keys {
+		action = m.HandleKey(key)
+	}
+
+	return action
+}
+
+func TestModelNavigation(t *testing.T) {
+	m := NewModel(testRows())
+	require.Equal(t, 1, m.Selected())
+
+	require.Equal(t, Action{Kind: ActionPreview, From: 2}, press(m, "j"))
+	require.Equal(t, Action{Kind: ActionPreview, From: 3}, press(m, KeyDown))
+	require.Equal(t, Action{}, press(m, "j", "j"))
+	require.Equal(t, 4, m.Selected())
+	require.Equal(t, Action{Kind: ActionPreview, From: 3}, press(m, KeyUp))
+	require.Equal(t, Action{Kind: ActionQuit}, press(m, "q"))
+}
+
+func TestModelAssign(t *testing.T) {
+	m := NewModel(testRows())
+
+	require.Equal(t, Action{}, press(m, "a"))
+	require.Equal(t, ModeBrowse, m.Mode())
+	require.Contains(t, m.Status(), "new commit")
+
+	require.Equal(t, Action{}, press(m, "j", "j", "a"))
+	require.Equal(t, ModeAssign, m.Mode())
+
+	// запись без review ветки не подходит
+	require.Equal(t, Action{}, press(m, "j", KeyEnter))
+	require.Equal(t, ModeAssign, m.Mode())
+
+	require.Equal(t, Action{Kind: ActionAssign, From: 3, To: 2}, press(m, "k", "k", KeyEnter))
+	require.Equal(t, ModeBrowse, m.Mode())
+}
+
+func TestModelMoveDrop(t *testing.T) {
+	m := NewModel(testRows())
+
+	require.Equal(t, Action{Kind: ActionMove, From: 1, To: 3}, press(m, "m", "j", "j", KeyEnter))
+
+	require.Equal(t, Action{}, press(m, "d", "n"))
+	require.Equal(t, Action{Kind: ActionDrop, From: 3}, press(m, "d", "y"))
+
+	require.Equal(t, Action{Kind: ActionPreview, From: 4}, press(m, "m", "j", KeyEscape, KeyEnter))
+	require.Equal(t, ModeBrowse, m.Mode())
+}
+
+func TestModelOpenMakeRefresh(t *testing.T) {
+	m := NewModel(testRows())
+
+	require.Equal(t, Action{Kind: ActionOpen, From: 1}, press(m, "o"))
+	require.Equal(t, Action{}, press(m, "j", "j", "o"))
+	require.Equal(t, Action{Kind: ActionMake}, press(m, "M"))
+	require.Equal(t, Action{Kind: ActionRefresh}, press(m, "r"))
+}
+
+func TestModelView(t *testing.T) {
+	m := NewModel(testRows())
+	m.SetPreview([]string{"diff --git a/x b/x", "+line"})
+	press(m, "j")
+
+	view := m.View(40, 10)
+	require.Len(t, view, 10)
+	require.Equal(t, "  1) ok 1111111 [fa/1] first", view[0])
+	require.Equal(t, "> 2) ** 2222222 [fa/2] second", view[1])
+	require.Equal(t, strings.Repeat("-", 40), view[4])
+	require.Equal(t, "diff --git a/x b/x", view[5])
+	require.Equal(t, "", view[8])
+
+	m.SetRows(testRows()[:1])
+	require.Equal(t, 1, m.Selected())
+}
+
+func TestParseKeys(t *testing.T) {
+	require.Equal(t,
+		[]Key{KeyUp, "j", KeyEnter, KeyEscape, KeyPageDown, "q", "ы"},
+		ParseKeys([]byte("\x1b[Aj\r\x1b\x1b[6~\x03ы")))
+}
+
+func TestClip(t *testing.T) {
+	tests := []struct {
+		line  string
+		width int
+		want  string
+	}{
+		{line: "1) ok 1111111", width: 5, want: "1) ok"},
+		{line: "1) ok", width: 10, want: "1) ok"},
+		{line: "1) ok", width: 0, want: "1) ok"},
+		{line: "a\tb", width: 3, want: "a  "},
+		{line: "1) \033[32mok\033[0m 1111111", width: 5, want: "1) \033[32mok\033[0m"},
+		{line: "1) \033[32mok\033[0m 1111111", width: 4, want: "1) \033[32mo\033[0m"},
+		{line: "1) \033[32mok\033[0m", width: 5, want: "1) \033[32mok\033[0m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			require.Equal(t, tt.want, clip(tt.line, tt.width))
+		})
+	}
+}
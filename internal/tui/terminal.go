@@ -0,0 +1,115 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Terminal терминал в raw режиме, режим переключается через stty
+type Terminal struct {
+	in    *os.File
+	out   io.Writer
+	saved string
+}
+
+func OpenTerminal() (*Terminal, error) {
+	t := &Terminal{in: os.Stdin, out: os.Stdout}
+
+	saved, err := t.stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("giiter ui needs a terminal: %w", err)
+	}
+
+	t.saved = saved
+
+	return t, t.Enter()
+}
+
+func (t *Terminal) stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = t.in
+
+	output, err := cmd.Output()
+
+	return strings.TrimSpace(string(output)), err
+}
+
+// Enter включает raw режим и альтернативный экран
+func (t *Terminal) Enter() error {
+	if _, err := t.stty("raw", "-echo"); err != nil {
+		return err
+	}
+
+	fmt.Fprint(t.out, "\x1b[?1049h\x1b[?25l")
+
+	return nil
+}
+
+// Leave возвращает обычный режим терминала, например для вывода команд giiter
+func (t *Terminal) Leave() error {
+	fmt.Fprint(t.out, "\x1b[?25h\x1b[?1049l")
+
+	_, err := t.stty(t.saved)
+
+	return err
+}
+
+// Size возвращает ширину и высоту терминала
+func (t *Terminal) Size() (int, int) {
+	output, err := t.stty("size")
+	if err != nil {
+		return 80, 24
+	}
+
+	var rows, cols int
+	if _, err := fmt.Sscan(output, &rows, &cols); err != nil || rows == 0 || cols == 0 {
+		return 80, 24
+	}
+
+	return cols, rows
+}
+
+// ReadKeys ждет нажатия и возвращает клавиши
+func (t *Terminal) ReadKeys() ([]Key, error) {
+	buf := make([]byte, 64)
+
+	n, err := t.in.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseKeys(buf[:n]), nil
+}
+
+// Draw перерисовывает экран
+func (t *Terminal) Draw(lines []string) {
+	var b strings.Builder
+
+	b.WriteString("\x1b[H\x1b[2J")
+
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+
+		b.WriteString(line)
+	}
+
+	fmt.Fprint(t.out, b.String())
+}
+
+// Pause ждет нажатия любой клавиши после вывода команды в обычном режиме
+func (t *Terminal) Pause() error {
+	fmt.Fprint(t.out, "\npress any key to return")
+
+	if _, err := t.stty("raw", "-echo"); err != nil {
+		return err
+	}
+
+	_, err := t.ReadKeys()
+
+	return err
+}